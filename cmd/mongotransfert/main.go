@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/mouradhm/mongo-transfert/pkg/activities"
+	"github.com/mouradhm/mongo-transfert/pkg/metrics"
 	"github.com/mouradhm/mongo-transfert/pkg/models"
 )
 
@@ -23,6 +26,16 @@ func main() {
 	batchSize := flag.Int("batch-size", 100, "Number of documents to transfer in a batch")
 	workerCount := flag.Int("workers", 3, "Number of parallel workers for collection transfer")
 	overwrite := flag.Bool("overwrite", false, "Overwrite existing collections in destination (default: false)")
+	stateFile := flag.String("state-file", "", "Path to a checkpoint file for resuming interrupted transfers (optional)")
+	maxRetries := flag.Int("max-retries", 5, "Maximum number of retries for transient MongoDB errors")
+	retryBaseDelay := flag.Duration("retry-base-delay", 200*time.Millisecond, "Base delay for exponential backoff between retries")
+	mode := flag.String("mode", "oneshot", "Transfer mode: oneshot (default), or incremental/oneshot-then-tail to keep tailing each collection's change stream after the bulk copy")
+	tailBatchInterval := flag.Duration("tail-batch-interval", 2*time.Second, "How often change-stream events are batched and applied in tail mode")
+	transformConfig := flag.String("transform-config", "", "Path to a YAML/JSON file with per-collection field renames, drops, PII hashing and/or filters (optional)")
+	metricsAddr := flag.String("metrics-addr", "", "Address to serve Prometheus metrics (/metrics) and live progress (/progress) on, e.g. :9090 (optional, disabled by default)")
+	intraCollectionWorkers := flag.Int("intra-collection-workers", 1, "Number of goroutines to partition a single collection's transfer across by _id range (default: 1, single-threaded)")
+	verify := flag.Bool("verify", false, "After each collection's transfer, verify source and destination are consistent via a count+checksum comparison; not supported together with --transform-config (default: false)")
+	verifySampleSize := flag.Int("verify-sample-size", 20, "Number of documents to sample-diff in full when a collection's verification checksum mismatches")
 
 	// Parse command-line flags
 	flag.Parse()
@@ -37,12 +50,22 @@ func main() {
 
 	// Create transfer parameters
 	params := models.TransferParams{
-		SourceURI:            *sourceURI,
-		DestinationURI:       *destURI,
-		SourceDB:             *sourceDB,
-		DestinationDB:        *destDB,
-		BatchSize:            *batchSize,
-		OverwriteDestination: *overwrite,
+		SourceURI:              *sourceURI,
+		DestinationURI:         *destURI,
+		SourceDB:               *sourceDB,
+		DestinationDB:          *destDB,
+		BatchSize:              *batchSize,
+		OverwriteDestination:   *overwrite,
+		StateFile:              *stateFile,
+		MaxRetries:             *maxRetries,
+		RetryBaseDelay:         *retryBaseDelay,
+		Mode:                   *mode,
+		TailBatchInterval:      *tailBatchInterval,
+		TransformConfigPath:    *transformConfig,
+		MetricsAddr:            *metricsAddr,
+		IntraCollectionWorkers: *intraCollectionWorkers,
+		Verify:                 *verify,
+		VerifySampleSize:       *verifySampleSize,
 	}
 
 	// Parse collections if provided
@@ -50,6 +73,9 @@ func main() {
 		params.Collections = parseCommaSeparatedList(*collections)
 	}
 
+	metricsServer := startMetricsServer(params.MetricsAddr)
+	defer stopMetricsServer(context.Background(), metricsServer)
+
 	// Run the transfer process
 	result, err := runTransfer(params, *workerCount)
 	if err != nil {
@@ -109,6 +135,16 @@ func runTransfer(params models.TransferParams, workerCount int) (models.Transfer
 		OverallSuccess: true,
 	}
 
+	// Verification compares raw per-document checksums and byte-for-byte
+	// content between source and destination; a transform pipeline that
+	// renames, drops, hashes, or filters fields makes the destination
+	// intentionally differ, which verification would then misreport as
+	// corruption. Reject the combination up front instead of producing
+	// false failures.
+	if params.Verify && params.TransformConfigPath != "" {
+		return result, fmt.Errorf("--verify is not supported together with --transform-config: verification compares raw document checksums and content, which does not account for renamed, dropped, hashed, or filtered fields")
+	}
+
 	// Validate connections
 	log.Println("Validating MongoDB connections...")
 	err := activities.ValidateConnections(ctx, params)
@@ -135,6 +171,18 @@ func runTransfer(params models.TransferParams, workerCount int) (models.Transfer
 		workerCount = 3 // Default number of workers
 	}
 
+	// Build one shared checkpoint store for the whole run (bulk copy and any
+	// subsequent tailing): every worker transferring a different collection
+	// must read/write the same in-memory state under one mutex, since each
+	// Save/MarkDone rewrites the entire state file.
+	var checkpointStore activities.CheckpointStore
+	if params.StateFile != "" {
+		checkpointStore, err = activities.NewFileCheckpointStore(params.StateFile)
+		if err != nil {
+			return result, fmt.Errorf("failed to load checkpoint store: %w", err)
+		}
+	}
+
 	// Create a channel for collection names
 	collectionCh := make(chan string, len(collections))
 	for _, collection := range collections {
@@ -154,7 +202,9 @@ func runTransfer(params models.TransferParams, workerCount int) (models.Transfer
 
 			for collName := range collectionCh {
 				log.Printf("Worker %d: Processing collection %s", workerId, collName)
-				res, err := activities.TransferCollection(ctx, params, collName)
+				metrics.ActiveWorkers.Inc()
+				res, err := activities.TransferCollection(ctx, params, collName, checkpointStore)
+				metrics.ActiveWorkers.Dec()
 				if err != nil {
 					log.Printf("Worker %d: Error transferring collection %s: %v", workerId, collName, err)
 				}
@@ -181,9 +231,65 @@ func runTransfer(params models.TransferParams, workerCount int) (models.Transfer
 	result.TotalDocuments = totalDocs
 	result.OverallSuccess = allSuccessful
 
+	if allSuccessful && isTailMode(params.Mode) {
+		log.Println("Bulk copy complete, tailing change streams until interrupted (Ctrl-C / SIGTERM)...")
+		if err := tailCollections(params, collections, checkpointStore); err != nil {
+			result.OverallSuccess = false
+			return result, fmt.Errorf("tailing failed: %w", err)
+		}
+	}
+
 	return result, nil
 }
 
+// isTailMode reports whether mode keeps tailing each collection's change
+// stream after the initial bulk copy finishes.
+func isTailMode(mode string) bool {
+	return mode == "incremental" || mode == "oneshot-then-tail"
+}
+
+// tailCollections runs a change-stream tailer per collection until a
+// SIGINT/SIGTERM is received, then lets each tailer drain its in-flight
+// batch and flush its resume token before returning.
+func tailCollections(params models.TransferParams, collections []string, checkpointStore activities.CheckpointStore) error {
+	tailCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	go func() {
+		sig := <-sigCh
+		log.Printf("Received %v, draining in-flight change events...", sig)
+		cancel()
+	}()
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(collections))
+	for _, collName := range collections {
+		wg.Add(1)
+		go func(collName string) {
+			defer wg.Done()
+			if err := activities.TailCollection(tailCtx, params, collName, checkpointStore); err != nil {
+				errCh <- fmt.Errorf("tail %s: %w", collName, err)
+			}
+		}(collName)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	var firstErr error
+	for err := range errCh {
+		log.Printf("Tail error: %v", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
 // printSummary prints a summary of the transfer results
 func printSummary(result models.TransferResult) {
 	fmt.Println("\n=== MongoDB Transfer Summary ===")
@@ -200,6 +306,13 @@ func printSummary(result models.TransferResult) {
 			successCount++
 		}
 		fmt.Printf("  - %s: %d documents, %s\n", collResult.CollectionName, collResult.DocumentsCount, status)
+		if collResult.SourceCount > 0 || collResult.DestCount > 0 {
+			fmt.Printf("      verify: source=%d dest=%d checksumMatch=%v\n",
+				collResult.SourceCount, collResult.DestCount, collResult.ChecksumMatch)
+			for _, diff := range collResult.SampledDiffs {
+				fmt.Printf("      diff: %s\n", diff)
+			}
+		}
 	}
 
 	fmt.Printf("\nSuccessfully transferred %d out of %d collections\n", successCount, len(result.CollectionResults))
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/mouradhm/mongo-transfert/pkg/progress"
+)
+
+// startMetricsServer starts an HTTP server on addr exposing Prometheus
+// metrics at /metrics and live transfer progress at /progress. It returns
+// nil if addr is empty.
+func startMetricsServer(addr string) *http.Server {
+	if addr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/progress", progressHandler)
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		log.Printf("Metrics server listening on %s (/metrics, /progress)", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Metrics server error: %v", err)
+		}
+	}()
+
+	return server
+}
+
+// progressResponse is the JSON shape served at /progress for one collection.
+type progressResponse struct {
+	CollectionName   string  `json:"collectionName"`
+	DocumentsCount   int64   `json:"documentsCount"`
+	TotalCount       int64   `json:"totalCount"`
+	BytesTransferred int64   `json:"bytesTransferred"`
+	DocsPerSecond    float64 `json:"docsPerSecond"`
+	BytesPerSecond   float64 `json:"bytesPerSecond"`
+	ETASeconds       float64 `json:"etaSeconds"`
+	Done             bool    `json:"done"`
+}
+
+func progressHandler(w http.ResponseWriter, r *http.Request) {
+	snapshot := progress.Snapshot()
+
+	out := make([]progressResponse, 0, len(snapshot))
+	for _, cp := range snapshot {
+		out = append(out, progressResponse{
+			CollectionName:   cp.CollectionName,
+			DocumentsCount:   cp.DocumentsCount,
+			TotalCount:       cp.TotalCount,
+			BytesTransferred: cp.BytesTransferred,
+			DocsPerSecond:    cp.DocsPerSecond(),
+			BytesPerSecond:   cp.BytesPerSecond(),
+			ETASeconds:       cp.ETA().Seconds(),
+			Done:             cp.Done,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Printf("Failed to encode progress response: %v", err)
+	}
+}
+
+// stopMetricsServer gracefully shuts down server, if non-nil.
+func stopMetricsServer(ctx context.Context, server *http.Server) {
+	if server == nil {
+		return
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down metrics server: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package activities
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// checkpointFlushBatches controls how often a resumed transfer fsyncs its
+// progress to the state file. A smaller value limits how much work is
+// redone after a crash at the cost of more frequent disk writes.
+const checkpointFlushBatches = 10
+
+// CheckpointEntry records the resume state for a single collection transfer.
+type CheckpointEntry struct {
+	// LastIDBytes is the BSON encoding of {"_id": <last processed id>}. It is
+	// stored raw (rather than as a hex string) so non-ObjectID _id types
+	// (string, int, UUID, ...) round-trip without special-casing.
+	LastIDBytes    []byte    `json:"lastId,omitempty"`
+	ProcessedCount int       `json:"processedCount"`
+	StartedAt      time.Time `json:"startedAt"`
+	Done           bool      `json:"done"`
+
+	// ResumeTokenBytes is the raw BSON change-stream resume token for this
+	// collection, persisted so a restarted tailer picks up where it left off.
+	ResumeTokenBytes []byte `json:"resumeToken,omitempty"`
+
+	// Partitions holds per-partition resume state when the collection was
+	// transferred with multiple intra-collection workers. Empty when the
+	// collection was (or is being) transferred single-threaded.
+	Partitions []PartitionCheckpoint `json:"partitions,omitempty"`
+}
+
+// PartitionCheckpoint records resume state for one _id-range partition of a
+// collection transferred with multiple intra-collection workers.
+type PartitionCheckpoint struct {
+	// LastIDBytes is the BSON encoding of {"_id": <last processed id>},
+	// relative to this partition's own range, not the whole collection.
+	LastIDBytes    []byte `json:"lastId,omitempty"`
+	ProcessedCount int    `json:"processedCount"`
+	Done           bool   `json:"done"`
+
+	// LoBytes and HiBytes are the BSON encodings of {"_id": <bound>} for
+	// this partition's resolved lower/upper _id range bounds (nil encodes
+	// an unbounded side). They pin down which _id range this partition's
+	// LastIDBytes/ProcessedCount are relative to, so a resumed run can
+	// confirm the boundaries it recomputes this time still match the
+	// interrupted run's: the $sample-based boundary fallback produces
+	// different boundaries on every invocation, and resuming against
+	// shifted ranges would silently skip or re-read documents.
+	LoBytes []byte `json:"lo,omitempty"`
+	HiBytes []byte `json:"hi,omitempty"`
+}
+
+// CheckpointStore persists per-collection resume state so that an
+// interrupted transfer can continue from the last successfully copied
+// document instead of starting over.
+type CheckpointStore interface {
+	// Load returns the checkpoint for collectionName, or ok=false if no
+	// checkpoint has been recorded yet.
+	Load(collectionName string) (entry CheckpointEntry, ok bool, err error)
+	// Save persists the checkpoint for collectionName and fsyncs it to disk.
+	Save(collectionName string, entry CheckpointEntry) error
+	// MarkDone flags collectionName as fully transferred so that a rerun
+	// skips it.
+	MarkDone(collectionName string, entry CheckpointEntry) error
+}
+
+// fileCheckpointStore is a CheckpointStore backed by a single JSON file
+// keyed by collection name.
+type fileCheckpointStore struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]CheckpointEntry
+}
+
+// NewFileCheckpointStore loads (or creates) a JSON checkpoint file at path.
+func NewFileCheckpointStore(path string) (CheckpointStore, error) {
+	store := &fileCheckpointStore{
+		path:    path,
+		entries: make(map[string]CheckpointEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &store.entries); err != nil {
+			return nil, fmt.Errorf("failed to parse checkpoint file %s: %w", path, err)
+		}
+	}
+
+	return store, nil
+}
+
+func (s *fileCheckpointStore) Load(collectionName string) (CheckpointEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[collectionName]
+	return entry, ok, nil
+}
+
+func (s *fileCheckpointStore) Save(collectionName string, entry CheckpointEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[collectionName] = entry
+	return s.flushLocked()
+}
+
+func (s *fileCheckpointStore) MarkDone(collectionName string, entry CheckpointEntry) error {
+	entry.Done = true
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[collectionName] = entry
+	return s.flushLocked()
+}
+
+// flushLocked rewrites the checkpoint file and fsyncs it. Callers must hold
+// s.mu. The new contents are written to a temp file and renamed over path
+// so a crash mid-write can never leave a truncated or corrupt checkpoint
+// file behind.
+func (s *fileCheckpointStore) flushLocked() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint state: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint temp file %s: %w", tmpPath, err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write checkpoint temp file %s: %w", tmpPath, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync checkpoint temp file %s: %w", tmpPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint temp file %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to rename checkpoint temp file onto %s: %w", s.path, err)
+	}
+
+	return nil
+}
+
+// checkpointIDDoc is the single-field document used to round-trip an _id
+// value of any comparable type (ObjectID, string, int, UUID) through BSON.
+type checkpointIDDoc struct {
+	ID interface{} `bson:"_id"`
+}
+
+// encodeCheckpointID marshals id into the raw BSON form stored in a
+// CheckpointEntry.
+func encodeCheckpointID(id interface{}) ([]byte, error) {
+	data, err := bson.Marshal(checkpointIDDoc{ID: id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode checkpoint id: %w", err)
+	}
+	return data, nil
+}
+
+// decodeCheckpointID reverses encodeCheckpointID, returning a value usable
+// directly as a query bound (e.g. in a {"_id": {"$gt": id}} filter).
+func decodeCheckpointID(data []byte) (interface{}, error) {
+	var doc checkpointIDDoc
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint id: %w", err)
+	}
+	return doc.ID, nil
+}
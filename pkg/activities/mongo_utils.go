@@ -9,30 +9,59 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mouradhm/mongo-transfert/pkg/metrics"
+	"github.com/mouradhm/mongo-transfert/pkg/progress"
+	"github.com/mouradhm/mongo-transfert/pkg/transform"
 )
 
-// connectToMongoDB establishes a connection to MongoDB with the given URI
-func connectToMongoDB(ctx context.Context, uri string) (*mongo.Client, error) {
-	clientOptions := options.Client().ApplyURI(uri)
-	clientOptions.SetConnectTimeout(10 * time.Second)
+// approxBatchBytes estimates the on-the-wire size of batch by BSON-marshaling
+// each document. It is an approximation used only for throughput reporting,
+// so marshal errors are ignored and simply excluded from the total.
+func approxBatchBytes(batch []interface{}) int64 {
+	var total int64
+	for _, doc := range batch {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			continue
+		}
+		total += int64(len(raw))
+	}
+	return total
+}
 
-	// Add performance optimizations
-	clientOptions.SetMaxPoolSize(100)                  // Increase connection pool size
-	clientOptions.SetMinPoolSize(10)                   // Maintain minimum connections
-	clientOptions.SetMaxConnIdleTime(30 * time.Second) // Close idle connections after 30s
-	clientOptions.SetRetryWrites(true)                 // Enable retry for failed writes
-	clientOptions.SetRetryReads(true)                  // Enable retry for failed reads
-	clientOptions.SetCompressors([]string{"snappy"})   // Enable compression
+// connectToMongoDB establishes a connection to MongoDB with the given URI,
+// retrying transient connection errors with backoff.
+func connectToMongoDB(ctx context.Context, uri string, retryCfg retryConfig) (*mongo.Client, error) {
+	var client *mongo.Client
 
-	client, err := mongo.Connect(ctx, clientOptions)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
-	}
+	_, _, err := retryWithBackoff(ctx, retryCfg, "connect to MongoDB", func() error {
+		clientOptions := options.Client().ApplyURI(uri)
+		clientOptions.SetConnectTimeout(10 * time.Second)
+
+		// Add performance optimizations
+		clientOptions.SetMaxPoolSize(100)                  // Increase connection pool size
+		clientOptions.SetMinPoolSize(10)                   // Maintain minimum connections
+		clientOptions.SetMaxConnIdleTime(30 * time.Second) // Close idle connections after 30s
+		clientOptions.SetRetryWrites(true)                 // Enable retry for failed writes
+		clientOptions.SetRetryReads(true)                  // Enable retry for failed reads
+		clientOptions.SetCompressors([]string{"snappy"})   // Enable compression
 
-	// Ping the database to verify connection
-	err = client.Ping(ctx, nil)
+		c, err := mongo.Connect(ctx, clientOptions)
+		if err != nil {
+			return fmt.Errorf("failed to connect to MongoDB: %w", err)
+		}
+
+		// Ping the database to verify connection
+		if err := c.Ping(ctx, nil); err != nil {
+			return fmt.Errorf("failed to ping MongoDB: %w", err)
+		}
+
+		client = c
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+		return nil, err
 	}
 
 	return client, nil
@@ -109,6 +138,16 @@ func getCollectionIndexes(ctx context.Context, collection *mongo.Collection) ([]
 	return indexes, nil
 }
 
+// transferOutcome reports how many documents were transferred and how much
+// retrying was required along the way.
+type transferOutcome struct {
+	count           int
+	retryCount      int
+	lastRetryReason string
+	filteredCount   int
+	droppedCount    int
+}
+
 // transferCollection transfers a single collection from source to destination
 func transferCollection(
 	ctx context.Context,
@@ -119,7 +158,14 @@ func transferCollection(
 	collectionName string,
 	batchSize int,
 	overwriteDestination bool,
-) (int, error) {
+	checkpointStore CheckpointStore,
+	retryCfg retryConfig,
+	transformChain transform.Chain,
+	aggPipeline *transform.AggregationPipeline,
+	intraCollectionWorkers int,
+) (transferOutcome, error) {
+	outcome := transferOutcome{}
+
 	if batchSize <= 0 {
 		batchSize = 100 // Default batch size
 	}
@@ -127,91 +173,259 @@ func transferCollection(
 	sourceCollection := sourceClient.Database(sourceDB).Collection(collectionName)
 	destCollection := destClient.Database(destDB).Collection(collectionName)
 
-	// Check if destination collection exists
-	collections, err := destClient.Database(destDB).ListCollectionNames(ctx, bson.M{"name": collectionName})
-	if err != nil {
-		return 0, fmt.Errorf("failed to check destination collection: %w", err)
+	// Load any existing checkpoint before touching the destination
+	// collection, so a resumed transfer never re-drops what it already copied.
+	checkpoint := CheckpointEntry{}
+	resuming := false
+	hasPriorProgress := false
+	if checkpointStore != nil {
+		entry, ok, err := checkpointStore.Load(collectionName)
+		if err != nil {
+			return outcome, fmt.Errorf("failed to load checkpoint: %w", err)
+		}
+		if ok && entry.Done {
+			log.Printf("Collection %s already completed per checkpoint, skipping", collectionName)
+			outcome.count = entry.ProcessedCount
+			return outcome, nil
+		}
+		if ok {
+			// A partitioned transfer persists progress under entry.Partitions
+			// rather than entry.LastIDBytes, so prior progress must be
+			// detected independently of which one is populated.
+			hasPriorProgress = len(entry.LastIDBytes) > 0 || len(entry.Partitions) > 0
+			checkpoint = entry
+			if len(entry.LastIDBytes) > 0 {
+				resuming = true
+				log.Printf("Resuming %s from checkpoint (%d documents already processed)", collectionName, entry.ProcessedCount)
+			} else if len(entry.Partitions) > 0 {
+				log.Printf("Resuming %s from partitioned checkpoint (%d documents already processed)", collectionName, entry.ProcessedCount)
+			}
+		}
 	}
-
-	collectionExists := len(collections) > 0
-	if collectionExists && !overwriteDestination {
-		return 0, fmt.Errorf("destination collection %s already exists and overwrite is not enabled", collectionName)
+	if checkpoint.StartedAt.IsZero() {
+		checkpoint.StartedAt = time.Now()
 	}
 
-	// Get source collection indexes
-	indexes, err := getCollectionIndexes(ctx, sourceCollection)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get source collection indexes: %w", err)
+	filter := bson.D{}
+	if resuming {
+		lastID, err := decodeCheckpointID(checkpoint.LastIDBytes)
+		if err != nil {
+			return outcome, fmt.Errorf("failed to decode checkpoint for %s: %w", collectionName, err)
+		}
+		filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: lastID}}}}
 	}
 
-	// Drop the destination collection if it exists and overwrite is enabled
-	if collectionExists && overwriteDestination {
-		log.Printf("Dropping existing collection %s in destination", collectionName)
-		err = destCollection.Drop(ctx)
+	if !hasPriorProgress {
+		// Check if destination collection exists
+		collections, err := destClient.Database(destDB).ListCollectionNames(ctx, bson.M{"name": collectionName})
 		if err != nil {
-			return 0, fmt.Errorf("failed to drop destination collection: %w", err)
+			return outcome, fmt.Errorf("failed to check destination collection: %w", err)
 		}
-	}
 
-	// Create indexes in background for better performance
-	if len(indexes) > 0 {
-		for i := range indexes {
-			if indexes[i].Options == nil {
-				indexes[i].Options = options.Index()
-			}
-			indexes[i].Options.SetBackground(true) // Create indexes in background
+		collectionExists := len(collections) > 0
+		if collectionExists && !overwriteDestination {
+			return outcome, fmt.Errorf("destination collection %s already exists and overwrite is not enabled", collectionName)
 		}
-		_, err = destCollection.Indexes().CreateMany(ctx, indexes)
+
+		// Get source collection indexes
+		indexes, err := getCollectionIndexes(ctx, sourceCollection)
 		if err != nil {
-			return 0, fmt.Errorf("failed to create indexes: %w", err)
+			return outcome, fmt.Errorf("failed to get source collection indexes: %w", err)
+		}
+
+		// Drop the destination collection if it exists and overwrite is enabled
+		if collectionExists && overwriteDestination {
+			log.Printf("Dropping existing collection %s in destination", collectionName)
+			err = destCollection.Drop(ctx)
+			if err != nil {
+				return outcome, fmt.Errorf("failed to drop destination collection: %w", err)
+			}
+		}
+
+		// Create indexes in background for better performance
+		if len(indexes) > 0 {
+			for i := range indexes {
+				if indexes[i].Options == nil {
+					indexes[i].Options = options.Index()
+				}
+				indexes[i].Options.SetBackground(true) // Create indexes in background
+			}
+			_, err = destCollection.Indexes().CreateMany(ctx, indexes)
+			if err != nil {
+				return outcome, fmt.Errorf("failed to create indexes: %w", err)
+			}
+			log.Printf("Created %d indexes for collection %s", len(indexes), collectionName)
 		}
-		log.Printf("Created %d indexes for collection %s", len(indexes), collectionName)
 	}
 
 	// Count documents for reporting
 	count, err := sourceCollection.CountDocuments(ctx, bson.D{})
 	if err != nil {
-		return 0, fmt.Errorf("failed to count documents: %w", err)
+		return outcome, fmt.Errorf("failed to count documents: %w", err)
 	}
 
 	if count == 0 {
-		return 0, nil // Nothing to transfer
+		return outcome, nil // Nothing to transfer
+	}
+
+	// Intra-collection partitioning only applies to the plain Find path: an
+	// aggregation pipeline operates on the whole collection and can't be
+	// safely split by _id range.
+	if intraCollectionWorkers > 1 && aggPipeline == nil {
+		if handled, partOutcome, perr := transferCollectionPartitioned(
+			ctx, sourceCollection, destCollection, collectionName, count, batchSize,
+			intraCollectionWorkers, checkpointStore, checkpoint, retryCfg, transformChain,
+		); handled {
+			return partOutcome, perr
+		}
+		log.Printf("Falling back to single-threaded transfer for %s", collectionName)
 	}
 
+	progress.Start(collectionName, count)
+	if checkpoint.ProcessedCount > 0 {
+		progress.Add(collectionName, int64(checkpoint.ProcessedCount), 0)
+	}
+	metrics.SetProgress(collectionName, int64(checkpoint.ProcessedCount), count)
+
 	// Optimize read performance
 	findOptions := options.Find().
 		SetNoCursorTimeout(true).      // Prevent cursor timeout
 		SetAllowDiskUse(true).         // Allow disk use for large result sets
 		SetBatchSize(int32(batchSize)) // Match batch size for optimal performance
 
-	// Retrieve and insert documents in batches
-	cursor, err := sourceCollection.Find(ctx, bson.D{}, findOptions)
+	if checkpointStore != nil {
+		// Checkpointing requires a stable scan order so that "_id > lastID"
+		// never skips or repeats a document.
+		findOptions.SetSort(bson.D{{Key: "_id", Value: 1}})
+	}
+
+	// Retrieve and insert documents in batches. If an aggregation pipeline
+	// was configured for this collection, push the read down as an
+	// Aggregate instead of a Find, prepending a $match on the checkpoint
+	// bound so resuming still works.
+	var cursor *mongo.Cursor
+	readDesc := fmt.Sprintf("find on %s", collectionName)
+	if aggPipeline != nil {
+		readDesc = fmt.Sprintf("aggregate on %s", collectionName)
+	}
+
+	findRetries, findReason, err := retryWithBackoff(ctx, retryCfg, readDesc, func() error {
+		var c *mongo.Cursor
+		var ferr error
+		if aggPipeline != nil {
+			stages := bson.A{}
+			if resuming {
+				stages = append(stages, bson.D{{Key: "$match", Value: filter}})
+			}
+			stages = append(stages, aggPipeline.Stages...)
+			c, ferr = sourceCollection.Aggregate(ctx, stages, options.Aggregate().SetAllowDiskUse(true))
+		} else {
+			c, ferr = sourceCollection.Find(ctx, filter, findOptions)
+		}
+		if ferr != nil {
+			return ferr
+		}
+		cursor = c
+		return nil
+	})
+	outcome.retryCount += findRetries
+	if findReason != "" {
+		outcome.lastRetryReason = findReason
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to execute find: %w", err)
+		return outcome, fmt.Errorf("failed to execute find: %w", err)
 	}
 	defer cursor.Close(ctx)
 
-	totalTransferred := 0
+	totalTransferred := checkpoint.ProcessedCount
 	batch := make([]interface{}, 0, batchSize)
+	batchesSinceFlush := 0
 
 	// Optimize write performance
 	insertOptions := options.InsertMany().
 		SetOrdered(false) // Allow unordered inserts for better performance
 
+	// recordCheckpoint advances the in-memory checkpoint to the last
+	// document in batch and, every checkpointFlushBatches batches, fsyncs
+	// it to the state file.
+	recordCheckpoint := func(batch []interface{}) error {
+		if checkpointStore == nil || len(batch) == 0 {
+			return nil
+		}
+
+		lastDoc, ok := batch[len(batch)-1].(bson.M)
+		if !ok {
+			return nil
+		}
+
+		idBytes, err := encodeCheckpointID(lastDoc["_id"])
+		if err != nil {
+			return err
+		}
+		checkpoint.LastIDBytes = idBytes
+		checkpoint.ProcessedCount += len(batch)
+
+		batchesSinceFlush++
+		if batchesSinceFlush >= checkpointFlushBatches {
+			if err := checkpointStore.Save(collectionName, checkpoint); err != nil {
+				return fmt.Errorf("failed to save checkpoint: %w", err)
+			}
+			batchesSinceFlush = 0
+		}
+		return nil
+	}
+
+	// insertBatch inserts batch, retrying transient failures and splitting
+	// out documents that fail permanently, then advances the checkpoint.
+	insertBatch := func(batch []interface{}) error {
+		start := time.Now()
+		retries, dropped, reason, err := insertBatchWithRetry(ctx, destCollection, batch, insertOptions, retryCfg, collectionName)
+		outcome.retryCount += retries
+		outcome.droppedCount += dropped
+		if reason != "" {
+			outcome.lastRetryReason = reason
+		}
+		if err != nil {
+			return err
+		}
+
+		metrics.RecordBatch(collectionName, len(batch)-dropped, time.Since(start))
+		batchBytes := approxBatchBytes(batch)
+		metrics.RecordBytes("written", batchBytes)
+		progress.Add(collectionName, int64(len(batch)-dropped), batchBytes)
+		metrics.SetProgress(collectionName, int64(totalTransferred+len(batch)), count)
+
+		return recordCheckpoint(batch)
+	}
+
 	for cursor.Next(ctx) {
 		var document bson.M
 		err := cursor.Decode(&document)
 		if err != nil {
-			return totalTransferred, fmt.Errorf("failed to decode document: %w", err)
+			return outcome, fmt.Errorf("failed to decode document: %w", err)
+		}
+		metrics.RecordBytes("read", approxBatchBytes([]interface{}{document}))
+
+		if len(transformChain) > 0 {
+			transformed, keep, err := transformChain.Apply(document)
+			if err != nil {
+				return outcome, fmt.Errorf("failed to transform document: %w", err)
+			}
+			if !keep {
+				outcome.filteredCount++
+				metrics.RecordFiltered(collectionName, 1)
+				continue
+			}
+			document = transformed
 		}
 
 		batch = append(batch, document)
 
 		// Insert batch when it reaches the batch size
 		if len(batch) >= batchSize {
-			_, err = destCollection.InsertMany(ctx, batch, insertOptions)
-			if err != nil {
-				return totalTransferred, fmt.Errorf("failed to insert batch: %w", err)
+			if err := insertBatch(batch); err != nil {
+				outcome.count = totalTransferred - outcome.droppedCount
+				return outcome, fmt.Errorf("failed to insert batch: %w", err)
 			}
 			totalTransferred += len(batch)
 			batch = make([]interface{}, 0, batchSize)
@@ -227,16 +441,26 @@ func transferCollection(
 
 	// Insert the remaining documents
 	if len(batch) > 0 {
-		_, err = destCollection.InsertMany(ctx, batch, insertOptions)
-		if err != nil {
-			return totalTransferred, fmt.Errorf("failed to insert final batch: %w", err)
+		if err := insertBatch(batch); err != nil {
+			outcome.count = totalTransferred - outcome.droppedCount
+			return outcome, fmt.Errorf("failed to insert final batch: %w", err)
 		}
 		totalTransferred += len(batch)
 	}
 
+	outcome.count = totalTransferred - outcome.droppedCount
+
 	if err := cursor.Err(); err != nil {
-		return totalTransferred, fmt.Errorf("cursor error: %w", err)
+		return outcome, fmt.Errorf("cursor error: %w", err)
 	}
 
-	return totalTransferred, nil
+	if checkpointStore != nil {
+		if err := checkpointStore.MarkDone(collectionName, checkpoint); err != nil {
+			return outcome, fmt.Errorf("failed to mark checkpoint done: %w", err)
+		}
+	}
+
+	progress.Finish(collectionName)
+
+	return outcome, nil
 }
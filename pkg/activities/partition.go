@@ -0,0 +1,449 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mouradhm/mongo-transfert/pkg/metrics"
+	"github.com/mouradhm/mongo-transfert/pkg/progress"
+	"github.com/mouradhm/mongo-transfert/pkg/transform"
+)
+
+// idRange is a half-open [lo, hi) bound on _id used to partition a
+// collection for parallel intra-collection transfer. A nil lo/hi means "no
+// lower/upper bound" (the first/last partition).
+type idRange struct {
+	lo interface{}
+	hi interface{}
+}
+
+// isComparableIDType reports whether id is of a type range queries can
+// meaningfully partition on: an ObjectID, a number, a string, or a date.
+// Intra-collection partitioning requires this; any other _id type (e.g. an
+// embedded document) falls back to a single-threaded transfer.
+func isComparableIDType(id interface{}) bool {
+	switch id.(type) {
+	case primitive.ObjectID, string, int32, int64, int, float64, primitive.DateTime:
+		return true
+	default:
+		return false
+	}
+}
+
+// computePartitionBounds splits sourceCollection into numPartitions
+// contiguous _id ranges. It prefers $bucketAuto, a single aggregation pass,
+// and falls back to sampling numPartitions-1 boundary points when
+// $bucketAuto is unavailable.
+func computePartitionBounds(ctx context.Context, sourceCollection *mongo.Collection, numPartitions int) ([]idRange, error) {
+	if numPartitions <= 1 {
+		return []idRange{{}}, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$bucketAuto", Value: bson.D{
+			{Key: "groupBy", Value: "$_id"},
+			{Key: "buckets", Value: numPartitions},
+		}}},
+	}
+
+	cursor, err := sourceCollection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err == nil {
+		var buckets []struct {
+			ID struct {
+				Min interface{} `bson:"min"`
+				Max interface{} `bson:"max"`
+			} `bson:"_id"`
+		}
+		decodeErr := cursor.All(ctx, &buckets)
+		cursor.Close(ctx)
+		if decodeErr == nil && len(buckets) > 0 {
+			ranges := make([]idRange, len(buckets))
+			for i := range buckets {
+				ranges[i] = idRange{lo: buckets[i].ID.Min}
+				if i < len(buckets)-1 {
+					ranges[i].hi = buckets[i+1].ID.Min
+				}
+			}
+			return ranges, nil
+		}
+	}
+
+	log.Printf("$bucketAuto partitioning unavailable for %s, falling back to $sample-based boundaries", sourceCollection.Name())
+	return samplePartitionBounds(ctx, sourceCollection, numPartitions)
+}
+
+// samplePartitionBounds estimates numPartitions-1 boundary points via
+// random sampling, used when $bucketAuto is unavailable.
+func samplePartitionBounds(ctx context.Context, sourceCollection *mongo.Collection, numPartitions int) ([]idRange, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: numPartitions - 1}}}},
+		{{Key: "$project", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+
+	cursor, err := sourceCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample partition boundaries: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to read sampled partition boundaries: %w", err)
+	}
+
+	ranges := make([]idRange, 0, len(docs)+1)
+	var lo interface{}
+	for _, d := range docs {
+		ranges = append(ranges, idRange{lo: lo, hi: d["_id"]})
+		lo = d["_id"]
+	}
+	ranges = append(ranges, idRange{lo: lo})
+	return ranges, nil
+}
+
+// rangeFilter builds the {"_id": {...}} query filter for r, substituting
+// resumeAfter (the last _id a resumed partition already processed) for r's
+// own lower bound when set.
+func rangeFilter(r idRange, resumeAfter interface{}) bson.D {
+	idCond := bson.D{}
+	switch {
+	case resumeAfter != nil:
+		idCond = append(idCond, bson.E{Key: "$gt", Value: resumeAfter})
+	case r.lo != nil:
+		idCond = append(idCond, bson.E{Key: "$gte", Value: r.lo})
+	}
+	if r.hi != nil {
+		idCond = append(idCond, bson.E{Key: "$lt", Value: r.hi})
+	}
+	if len(idCond) == 0 {
+		return bson.D{}
+	}
+	return bson.D{{Key: "_id", Value: idCond}}
+}
+
+// reconcilePartitionBounds encodes each of ranges' bounds and either records
+// them on a brand-new partition checkpoint, or, if the partition already has
+// recorded progress, verifies the freshly-computed bounds still match what
+// was recorded before. It returns an error describing the first mismatch,
+// at which point the caller must not resume partitioned (the range
+// boundaries shifted under it, most likely because $bucketAuto was
+// unavailable and the $sample-based fallback produced different boundary
+// points this run).
+func reconcilePartitionBounds(partitionCheckpoints []PartitionCheckpoint, ranges []idRange) error {
+	for i, r := range ranges {
+		loBytes, err := encodeCheckpointID(r.lo)
+		if err != nil {
+			return fmt.Errorf("failed to encode partition %d lower bound: %w", i, err)
+		}
+		hiBytes, err := encodeCheckpointID(r.hi)
+		if err != nil {
+			return fmt.Errorf("failed to encode partition %d upper bound: %w", i, err)
+		}
+
+		pc := &partitionCheckpoints[i]
+		hasPriorProgress := pc.ProcessedCount > 0 || pc.Done
+		if !hasPriorProgress {
+			pc.LoBytes = loBytes
+			pc.HiBytes = hiBytes
+			continue
+		}
+
+		if !bytes.Equal(pc.LoBytes, loBytes) || !bytes.Equal(pc.HiBytes, hiBytes) {
+			return fmt.Errorf("partition %d bounds changed since the checkpoint was recorded", i)
+		}
+	}
+	return nil
+}
+
+// transferCollectionPartitioned attempts to transfer sourceCollection using
+// numWorkers concurrent goroutines, each owning a contiguous _id range. It
+// returns handled=false when partitioning doesn't apply (non-comparable
+// _id type, or fewer than two usable ranges), in which case the caller
+// should fall back to the single-threaded path.
+func transferCollectionPartitioned(
+	ctx context.Context,
+	sourceCollection *mongo.Collection,
+	destCollection *mongo.Collection,
+	collectionName string,
+	count int64,
+	batchSize int,
+	numWorkers int,
+	checkpointStore CheckpointStore,
+	checkpoint CheckpointEntry,
+	retryCfg retryConfig,
+	transformChain transform.Chain,
+) (handled bool, outcome transferOutcome, err error) {
+	var sample bson.M
+	if err := sourceCollection.FindOne(ctx, bson.D{}).Decode(&sample); err != nil {
+		return false, transferOutcome{}, nil
+	}
+	if !isComparableIDType(sample["_id"]) {
+		log.Printf("_id of %s is not a comparable type, skipping intra-collection partitioning", collectionName)
+		return false, transferOutcome{}, nil
+	}
+
+	ranges, err := computePartitionBounds(ctx, sourceCollection, numWorkers)
+	if err != nil {
+		log.Printf("Failed to compute partition bounds for %s: %v", collectionName, err)
+		return false, transferOutcome{}, nil
+	}
+	if len(ranges) < 2 {
+		return false, transferOutcome{}, nil
+	}
+
+	partitionCheckpoints := append([]PartitionCheckpoint{}, checkpoint.Partitions...)
+	for len(partitionCheckpoints) < len(ranges) {
+		partitionCheckpoints = append(partitionCheckpoints, PartitionCheckpoint{})
+	}
+
+	if err := reconcilePartitionBounds(partitionCheckpoints, ranges); err != nil {
+		log.Printf("Partition bounds for %s no longer match the interrupted run's checkpoint (%v), falling back to single-threaded recovery", collectionName, err)
+		return false, transferOutcome{}, nil
+	}
+
+	progress.Start(collectionName, count)
+	var alreadyProcessed int64
+	for _, pc := range partitionCheckpoints {
+		alreadyProcessed += int64(pc.ProcessedCount)
+	}
+	if alreadyProcessed > 0 {
+		progress.Add(collectionName, alreadyProcessed, 0)
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		totalOut transferOutcome
+	)
+
+	saveCheckpoint := func() {
+		if checkpointStore == nil {
+			return
+		}
+		checkpoint.Partitions = append([]PartitionCheckpoint{}, partitionCheckpoints...)
+		checkpoint.ProcessedCount = sumProcessed(partitionCheckpoints)
+		if err := checkpointStore.Save(collectionName, checkpoint); err != nil {
+			log.Printf("Failed to save partition checkpoint for %s: %v", collectionName, err)
+		}
+	}
+
+	for i, r := range ranges {
+		if partitionCheckpoints[i].Done {
+			totalOut.count += partitionCheckpoints[i].ProcessedCount
+			continue
+		}
+
+		wg.Add(1)
+		go func(idx int, r idRange) {
+			defer wg.Done()
+
+			pc := partitionCheckpoints[idx]
+			onProgress := func(processed int, lastID interface{}) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if idBytes, encErr := encodeCheckpointID(lastID); encErr == nil {
+					partitionCheckpoints[idx].LastIDBytes = idBytes
+				}
+				partitionCheckpoints[idx].ProcessedCount = processed
+				saveCheckpoint()
+			}
+
+			partOutcome, perr := transferPartition(ctx, sourceCollection, destCollection, collectionName, idx, r, batchSize, retryCfg, transformChain, pc, onProgress)
+
+			mu.Lock()
+			defer mu.Unlock()
+			totalOut.count += partOutcome.count
+			totalOut.retryCount += partOutcome.retryCount
+			totalOut.filteredCount += partOutcome.filteredCount
+			totalOut.droppedCount += partOutcome.droppedCount
+			if partOutcome.lastRetryReason != "" {
+				totalOut.lastRetryReason = partOutcome.lastRetryReason
+			}
+			if perr != nil {
+				if firstErr == nil {
+					firstErr = perr
+				}
+			} else {
+				partitionCheckpoints[idx].Done = true
+			}
+		}(i, r)
+	}
+
+	wg.Wait()
+
+	if checkpointStore != nil {
+		checkpoint.Partitions = partitionCheckpoints
+		checkpoint.ProcessedCount = sumProcessed(partitionCheckpoints)
+		if firstErr == nil {
+			if err := checkpointStore.MarkDone(collectionName, checkpoint); err != nil {
+				return true, totalOut, fmt.Errorf("failed to mark checkpoint done: %w", err)
+			}
+		} else if err := checkpointStore.Save(collectionName, checkpoint); err != nil {
+			log.Printf("Failed to save checkpoint for %s after partition error: %v", collectionName, err)
+		}
+	}
+
+	if firstErr != nil {
+		return true, totalOut, firstErr
+	}
+
+	progress.Finish(collectionName)
+	metrics.SetProgress(collectionName, int64(totalOut.count), count)
+
+	return true, totalOut, nil
+}
+
+// sumProcessed totals the documents processed across all partitions.
+func sumProcessed(partitions []PartitionCheckpoint) int {
+	total := 0
+	for _, p := range partitions {
+		total += p.ProcessedCount
+	}
+	return total
+}
+
+// transferPartition copies all documents in range r from sourceCollection
+// to destCollection, retrying transient errors and applying
+// transformChain, resuming from checkpoint's last processed _id if set.
+// onProgress is invoked after each successfully inserted batch so the
+// caller can persist partition-level checkpoint state.
+func transferPartition(
+	ctx context.Context,
+	sourceCollection *mongo.Collection,
+	destCollection *mongo.Collection,
+	collectionName string,
+	partitionIdx int,
+	r idRange,
+	batchSize int,
+	retryCfg retryConfig,
+	transformChain transform.Chain,
+	checkpoint PartitionCheckpoint,
+	onProgress func(processed int, lastID interface{}),
+) (transferOutcome, error) {
+	outcome := transferOutcome{}
+
+	var resumeAfter interface{}
+	if len(checkpoint.LastIDBytes) > 0 {
+		lastID, err := decodeCheckpointID(checkpoint.LastIDBytes)
+		if err != nil {
+			return outcome, fmt.Errorf("partition %d: failed to decode checkpoint: %w", partitionIdx, err)
+		}
+		resumeAfter = lastID
+	}
+
+	filter := rangeFilter(r, resumeAfter)
+
+	findOptions := options.Find().
+		SetNoCursorTimeout(true).
+		SetAllowDiskUse(true).
+		SetBatchSize(int32(batchSize)).
+		SetSort(bson.D{{Key: "_id", Value: 1}})
+
+	var cursor *mongo.Cursor
+	findRetries, findReason, err := retryWithBackoff(ctx, retryCfg, fmt.Sprintf("find on %s partition %d", collectionName, partitionIdx), func() error {
+		c, ferr := sourceCollection.Find(ctx, filter, findOptions)
+		if ferr != nil {
+			return ferr
+		}
+		cursor = c
+		return nil
+	})
+	outcome.retryCount += findRetries
+	if findReason != "" {
+		outcome.lastRetryReason = findReason
+	}
+	if err != nil {
+		return outcome, fmt.Errorf("partition %d: failed to execute find: %w", partitionIdx, err)
+	}
+	defer cursor.Close(ctx)
+
+	insertOptions := options.InsertMany().SetOrdered(false)
+
+	processed := checkpoint.ProcessedCount
+	batch := make([]interface{}, 0, batchSize)
+
+	insertBatch := func(batch []interface{}) error {
+		start := time.Now()
+		retries, dropped, reason, err := insertBatchWithRetry(ctx, destCollection, batch, insertOptions, retryCfg, collectionName)
+		outcome.retryCount += retries
+		outcome.droppedCount += dropped
+		if reason != "" {
+			outcome.lastRetryReason = reason
+		}
+		if err != nil {
+			return err
+		}
+
+		metrics.RecordBatch(collectionName, len(batch)-dropped, time.Since(start))
+		batchBytes := approxBatchBytes(batch)
+		metrics.RecordBytes("written", batchBytes)
+		progress.Add(collectionName, int64(len(batch)-dropped), batchBytes)
+
+		processed += len(batch)
+		if onProgress != nil {
+			var lastID interface{}
+			if lastDoc, ok := batch[len(batch)-1].(bson.M); ok {
+				lastID = lastDoc["_id"]
+			}
+			onProgress(processed, lastID)
+		}
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var document bson.M
+		if err := cursor.Decode(&document); err != nil {
+			return outcome, fmt.Errorf("partition %d: failed to decode document: %w", partitionIdx, err)
+		}
+		metrics.RecordBytes("read", approxBatchBytes([]interface{}{document}))
+
+		if len(transformChain) > 0 {
+			transformed, keep, err := transformChain.Apply(document)
+			if err != nil {
+				return outcome, fmt.Errorf("partition %d: failed to transform document: %w", partitionIdx, err)
+			}
+			if !keep {
+				outcome.filteredCount++
+				metrics.RecordFiltered(collectionName, 1)
+				continue
+			}
+			document = transformed
+		}
+
+		batch = append(batch, document)
+		if len(batch) >= batchSize {
+			if err := insertBatch(batch); err != nil {
+				outcome.count = processed - outcome.droppedCount
+				return outcome, fmt.Errorf("partition %d: failed to insert batch: %w", partitionIdx, err)
+			}
+			batch = make([]interface{}, 0, batchSize)
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := insertBatch(batch); err != nil {
+			outcome.count = processed - outcome.droppedCount
+			return outcome, fmt.Errorf("partition %d: failed to insert final batch: %w", partitionIdx, err)
+		}
+	}
+
+	outcome.count = processed - outcome.droppedCount
+
+	if err := cursor.Err(); err != nil {
+		return outcome, fmt.Errorf("partition %d: cursor error: %w", partitionIdx, err)
+	}
+
+	return outcome, nil
+}
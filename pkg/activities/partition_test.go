@@ -0,0 +1,179 @@
+package activities
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIsComparableIDType(t *testing.T) {
+	cases := []struct {
+		name string
+		id   interface{}
+		want bool
+	}{
+		{"object id", primitive.NewObjectID(), true},
+		{"string", "abc", true},
+		{"int32", int32(1), true},
+		{"int64", int64(1), true},
+		{"int", 1, true},
+		{"float64", 1.5, true},
+		{"date", primitive.NewDateTimeFromTime(primitive.DateTime(0).Time()), true},
+		{"embedded document", bson.M{"a": 1}, false},
+		{"nil", nil, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isComparableIDType(tc.id); got != tc.want {
+				t.Errorf("isComparableIDType(%v) = %v, want %v", tc.id, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRangeFilter(t *testing.T) {
+	cases := []struct {
+		name        string
+		r           idRange
+		resumeAfter interface{}
+		want        bson.D
+	}{
+		{
+			name: "unbounded range with no resume",
+			r:    idRange{},
+			want: bson.D{},
+		},
+		{
+			name: "lower bound only",
+			r:    idRange{lo: 10},
+			want: bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: 10}}}},
+		},
+		{
+			name: "upper bound only",
+			r:    idRange{hi: 20},
+			want: bson.D{{Key: "_id", Value: bson.D{{Key: "$lt", Value: 20}}}},
+		},
+		{
+			name: "both bounds",
+			r:    idRange{lo: 10, hi: 20},
+			want: bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: 10}, {Key: "$lt", Value: 20}}}},
+		},
+		{
+			name:        "resumeAfter overrides the lower bound",
+			r:           idRange{lo: 10, hi: 20},
+			resumeAfter: 15,
+			want:        bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: 15}, {Key: "$lt", Value: 20}}}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := rangeFilter(tc.r, tc.resumeAfter)
+			if !bsonDEqual(got, tc.want) {
+				t.Errorf("rangeFilter() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+// bsonDEqual compares two bson.D values key by key, recursing into nested
+// bson.D values. Other values are compared with ==, so the same number held
+// in different integer types (e.g. int and int32) is not equal.
+func bsonDEqual(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key {
+			return false
+		}
+		aNested, aOK := a[i].Value.(bson.D)
+		bNested, bOK := b[i].Value.(bson.D)
+		if aOK != bOK {
+			return false
+		}
+		if aOK {
+			if !bsonDEqual(aNested, bNested) {
+				return false
+			}
+			continue
+		}
+		if a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReconcilePartitionBoundsRecordsFreshBounds(t *testing.T) {
+	ranges := []idRange{{hi: 10}, {lo: 10, hi: 20}, {lo: 20}}
+	partitionCheckpoints := make([]PartitionCheckpoint, len(ranges))
+
+	if err := reconcilePartitionBounds(partitionCheckpoints, ranges); err != nil {
+		t.Fatalf("reconcilePartitionBounds returned error: %v", err)
+	}
+
+	for i, pc := range partitionCheckpoints {
+		if len(pc.LoBytes) == 0 || len(pc.HiBytes) == 0 {
+			t.Errorf("partition %d: bounds not recorded: %+v", i, pc)
+		}
+	}
+}
+
+func TestReconcilePartitionBoundsAcceptsMatchingResume(t *testing.T) {
+	ranges := []idRange{{hi: 10}, {lo: 10, hi: 20}}
+	partitionCheckpoints := make([]PartitionCheckpoint, len(ranges))
+	if err := reconcilePartitionBounds(partitionCheckpoints, ranges); err != nil {
+		t.Fatalf("reconcilePartitionBounds returned error: %v", err)
+	}
+
+	partitionCheckpoints[0].ProcessedCount = 3
+
+	if err := reconcilePartitionBounds(partitionCheckpoints, ranges); err != nil {
+		t.Fatalf("reconcilePartitionBounds returned error on an unchanged resume: %v", err)
+	}
+}
+
+func TestReconcilePartitionBoundsRejectsShiftedResume(t *testing.T) {
+	original := []idRange{{hi: 10}, {lo: 10, hi: 20}}
+	partitionCheckpoints := make([]PartitionCheckpoint, len(original))
+	if err := reconcilePartitionBounds(partitionCheckpoints, original); err != nil {
+		t.Fatalf("reconcilePartitionBounds returned error: %v", err)
+	}
+	partitionCheckpoints[1].ProcessedCount = 3
+
+	shifted := []idRange{{hi: 12}, {lo: 12, hi: 22}}
+	if err := reconcilePartitionBounds(partitionCheckpoints, shifted); err == nil {
+		t.Errorf("reconcilePartitionBounds() expected an error when a processed partition's bounds shifted")
+	}
+}
+
+func TestReconcilePartitionBoundsRejectsUnverifiableLegacyCheckpoint(t *testing.T) {
+	ranges := []idRange{{hi: 10}, {lo: 10, hi: 20}}
+	partitionCheckpoints := []PartitionCheckpoint{
+		{ProcessedCount: 3}, // progress recorded before LoBytes/HiBytes existed
+		{},
+	}
+
+	if err := reconcilePartitionBounds(partitionCheckpoints, ranges); err == nil {
+		t.Errorf("reconcilePartitionBounds() expected an error for a legacy checkpoint with no recorded bounds")
+	}
+}
+
+func TestSumProcessed(t *testing.T) {
+	partitions := []PartitionCheckpoint{
+		{ProcessedCount: 10},
+		{ProcessedCount: 5},
+		{ProcessedCount: 0},
+	}
+
+	if got := sumProcessed(partitions); got != 15 {
+		t.Errorf("sumProcessed() = %d, want 15", got)
+	}
+
+	if got := sumProcessed(nil); got != 0 {
+		t.Errorf("sumProcessed(nil) = %d, want 0", got)
+	}
+}
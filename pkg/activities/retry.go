@@ -0,0 +1,208 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mouradhm/mongo-transfert/pkg/metrics"
+)
+
+const (
+	defaultMaxRetries     = 5
+	defaultRetryBaseDelay = 200 * time.Millisecond
+	maxRetryDelay         = 30 * time.Second
+)
+
+// transientCommandErrorCodes are MongoDB server error codes worth retrying:
+// InterruptedAtShutdown (11600), InterruptedDueToReplStateChange (11602),
+// PrimarySteppedDown (189), ShutdownInProgress (91) and WriteConflict (112).
+// Notably absent is DuplicateKey (11000), which is permanent.
+var transientCommandErrorCodes = map[int32]bool{
+	11600: true,
+	11602: true,
+	189:   true,
+	91:    true,
+	112:   true,
+}
+
+// retryConfig controls retryWithBackoff's behavior.
+type retryConfig struct {
+	maxRetries int
+	baseDelay  time.Duration
+}
+
+// newRetryConfig builds a retryConfig from the user-facing params, falling
+// back to sane defaults when left unset.
+func newRetryConfig(maxRetries int, baseDelay time.Duration) retryConfig {
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	if baseDelay <= 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+	return retryConfig{maxRetries: maxRetries, baseDelay: baseDelay}
+}
+
+// isTransientError reports whether err is a transient MongoDB error (network
+// timeout, write conflict, replica-set state change, ...) that is safe to
+// retry, as opposed to a permanent one (duplicate key, auth failure,
+// namespace not found, ...).
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if mongo.IsTimeout(err) || mongo.IsNetworkError(err) {
+		return true
+	}
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && transientCommandErrorCodes[cmdErr.Code] {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		for code := range transientCommandErrorCodes {
+			if serverErr.HasErrorCode(int(code)) {
+				return true
+			}
+		}
+	}
+
+	// The Go driver surfaces a cleared connection pool as a plain string,
+	// not a typed error.
+	return strings.Contains(err.Error(), "connection pool cleared") ||
+		strings.Contains(err.Error(), "connection pool closed")
+}
+
+// retryReasonLabel classifies err into a low-cardinality label suitable for
+// the mongo_transfer_retries_total{reason} metric. Unlike lastReason (the
+// full error message, used for human-facing reporting), this must stay
+// bounded regardless of how many distinct error messages a deployment sees.
+func retryReasonLabel(err error) string {
+	if err == nil {
+		return "unknown"
+	}
+
+	if mongo.IsTimeout(err) {
+		return "timeout"
+	}
+	if mongo.IsNetworkError(err) {
+		return "network"
+	}
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return fmt.Sprintf("command_%d", cmdErr.Code)
+	}
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return "server_error"
+	}
+
+	if strings.Contains(err.Error(), "connection pool cleared") {
+		return "pool_cleared"
+	}
+	if strings.Contains(err.Error(), "connection pool closed") {
+		return "pool_closed"
+	}
+
+	return "other"
+}
+
+// retryWithBackoff invokes fn, retrying with exponential backoff and jitter
+// while the returned error is transient. It reports how many retries were
+// performed and, if any were, a human-readable reason for the last one.
+func retryWithBackoff(ctx context.Context, cfg retryConfig, operation string, fn func() error) (retries int, lastReason string, err error) {
+	cfg = newRetryConfig(cfg.maxRetries, cfg.baseDelay)
+
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil {
+			return retries, lastReason, nil
+		}
+		if !isTransientError(err) || attempt >= cfg.maxRetries {
+			return retries, lastReason, err
+		}
+
+		delay := cfg.baseDelay * time.Duration(1<<uint(attempt))
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+		delay += time.Duration(rand.Int63n(int64(cfg.baseDelay) + 1))
+
+		retries++
+		lastReason = err.Error()
+		metrics.RecordRetry(retryReasonLabel(err))
+		log.Printf("Retrying %s after transient error (attempt %d/%d, waiting %s): %v",
+			operation, attempt+1, cfg.maxRetries, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return retries, lastReason, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// insertBatchWithRetry inserts batch into destCollection, retrying
+// transient errors with backoff. If the driver reports a partial
+// mongo.BulkWriteException, only the documents that failed for a
+// transient reason are resent; documents that failed permanently (e.g.
+// duplicate key) are logged and dropped so one bad document cannot keep
+// retrying forever or abort an otherwise-successful batch. dropped reports
+// how many documents were dropped this way, so callers don't count them as
+// transferred.
+func insertBatchWithRetry(ctx context.Context, destCollection *mongo.Collection, batch []interface{}, insertOptions *options.InsertManyOptions, cfg retryConfig, collectionName string) (retries int, dropped int, lastReason string, err error) {
+	pending := batch
+
+	for len(pending) > 0 {
+		attemptRetries, reason, insertErr := retryWithBackoff(ctx, cfg, fmt.Sprintf("insert batch for %s", collectionName), func() error {
+			_, e := destCollection.InsertMany(ctx, pending, insertOptions)
+			return e
+		})
+		retries += attemptRetries
+		if reason != "" {
+			lastReason = reason
+		}
+
+		if insertErr == nil {
+			return retries, dropped, lastReason, nil
+		}
+
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(insertErr, &bulkErr) {
+			return retries, dropped, lastReason, insertErr
+		}
+
+		lastReason = insertErr.Error()
+
+		var retryable []interface{}
+		for _, we := range bulkErr.WriteErrors {
+			if transientCommandErrorCodes[int32(we.Code)] {
+				retryable = append(retryable, pending[we.Index])
+				continue
+			}
+			dropped++
+			log.Printf("Dropping permanently-failing document in batch for %s (code %d): %s",
+				collectionName, we.Code, we.Message)
+		}
+
+		if len(retryable) == len(pending) {
+			// Nothing was resolved by splitting the batch, avoid looping forever.
+			return retries, dropped, lastReason, insertErr
+		}
+		pending = retryable
+	}
+
+	return retries, dropped, lastReason, nil
+}
@@ -0,0 +1,131 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIsTransientError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil error", nil, false},
+		{"plain error", errors.New("boom"), false},
+		{"transient command error", mongo.CommandError{Code: 11602, Message: "InterruptedDueToReplStateChange"}, true},
+		{"duplicate key is permanent", mongo.CommandError{Code: 11000, Message: "E11000 duplicate key"}, false},
+		{"cleared connection pool", errors.New("connection pool cleared"), true},
+		{"closed connection pool", errors.New("connection pool closed"), true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTransientError(tc.err); got != tc.want {
+				t.Errorf("isTransientError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryReasonLabel(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil error", nil, "unknown"},
+		{"command error", mongo.CommandError{Code: 189, Message: "PrimarySteppedDown"}, "command_189"},
+		{"cleared connection pool", errors.New("connection pool cleared"), "pool_cleared"},
+		{"closed connection pool", errors.New("connection pool closed"), "pool_closed"},
+		{"unrecognized error", errors.New("boom"), "other"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := retryReasonLabel(tc.err); got != tc.want {
+				t.Errorf("retryReasonLabel(%v) = %q, want %q", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewRetryConfigDefaults(t *testing.T) {
+	cfg := newRetryConfig(0, 0)
+	if cfg.maxRetries != defaultMaxRetries {
+		t.Errorf("maxRetries = %d, want %d", cfg.maxRetries, defaultMaxRetries)
+	}
+	if cfg.baseDelay != defaultRetryBaseDelay {
+		t.Errorf("baseDelay = %v, want %v", cfg.baseDelay, defaultRetryBaseDelay)
+	}
+
+	cfg = newRetryConfig(3, 10*time.Millisecond)
+	if cfg.maxRetries != 3 || cfg.baseDelay != 10*time.Millisecond {
+		t.Errorf("newRetryConfig did not preserve explicit values, got %+v", cfg)
+	}
+}
+
+func TestRetryWithBackoffSucceedsAfterTransientErrors(t *testing.T) {
+	attempts := 0
+	cfg := retryConfig{maxRetries: 3, baseDelay: time.Millisecond}
+
+	retries, lastReason, err := retryWithBackoff(context.Background(), cfg, "test op", func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection pool cleared")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("retryWithBackoff returned error: %v", err)
+	}
+	if retries != 2 {
+		t.Errorf("retries = %d, want 2", retries)
+	}
+	if lastReason == "" {
+		t.Errorf("lastReason = %q, want non-empty", lastReason)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestRetryWithBackoffStopsOnPermanentError(t *testing.T) {
+	attempts := 0
+	cfg := retryConfig{maxRetries: 3, baseDelay: time.Millisecond}
+	permanentErr := errors.New("boom")
+
+	_, _, err := retryWithBackoff(context.Background(), cfg, "test op", func() error {
+		attempts++
+		return permanentErr
+	})
+
+	if !errors.Is(err, permanentErr) {
+		t.Errorf("retryWithBackoff returned %v, want %v", err, permanentErr)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 (no retries for a permanent error)", attempts)
+	}
+}
+
+func TestRetryWithBackoffGivesUpAfterMaxRetries(t *testing.T) {
+	attempts := 0
+	cfg := retryConfig{maxRetries: 2, baseDelay: time.Millisecond}
+
+	_, _, err := retryWithBackoff(context.Background(), cfg, "test op", func() error {
+		attempts++
+		return errors.New("connection pool cleared")
+	})
+
+	if err == nil {
+		t.Fatalf("retryWithBackoff() expected an error once retries are exhausted")
+	}
+	if attempts != cfg.maxRetries+1 {
+		t.Errorf("attempts = %d, want %d (initial try + %d retries)", attempts, cfg.maxRetries+1, cfg.maxRetries)
+	}
+}
@@ -0,0 +1,212 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mouradhm/mongo-transfert/pkg/models"
+)
+
+// defaultTailBatchInterval is how often tailed change-stream events are
+// batched and applied when TransferParams.TailBatchInterval is unset.
+const defaultTailBatchInterval = 2 * time.Second
+
+// tailMaxAwaitTime bounds how long each change-stream getMore blocks
+// server-side waiting for a new event. Without it, TryNext returns
+// immediately when idle and the default branch below busy-polls in a tight
+// loop for the lifetime of the tail.
+const tailMaxAwaitTime = 1 * time.Second
+
+// changeEvent is the subset of a MongoDB change stream event TailCollection
+// needs to replicate a write to the destination.
+type changeEvent struct {
+	OperationType string   `bson:"operationType"`
+	DocumentKey   bson.Raw `bson:"documentKey"`
+	FullDocument  bson.Raw `bson:"fullDocument"`
+}
+
+// TailCollection opens a change stream on a source collection and applies
+// insert/update/replace/delete events to the destination collection. It
+// runs until ctx is cancelled (e.g. on SIGTERM), batching events by
+// params.TailBatchInterval for throughput and persisting the stream's
+// resume token to the checkpoint store after every flush so a restart
+// resumes tailing instead of replaying from the beginning. checkpointStore
+// is nil unless params.StateFile is set, in which case the caller must
+// share a single instance across every concurrently-tailed collection (see
+// TransferCollection's checkpointStore doc comment for why).
+func TailCollection(ctx context.Context, params models.TransferParams, collectionName string, checkpointStore CheckpointStore) error {
+	retryCfg := newRetryConfig(params.MaxRetries, params.RetryBaseDelay)
+
+	sourceClient, err := connectToMongoDB(ctx, params.SourceURI, retryCfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to source MongoDB: %w", err)
+	}
+	defer func() {
+		if err := sourceClient.Disconnect(context.Background()); err != nil {
+			log.Printf("Error disconnecting from source MongoDB: %v", err)
+		}
+	}()
+
+	destClient, err := connectToMongoDB(ctx, params.DestinationURI, retryCfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to destination MongoDB: %w", err)
+	}
+	defer func() {
+		if err := destClient.Disconnect(context.Background()); err != nil {
+			log.Printf("Error disconnecting from destination MongoDB: %v", err)
+		}
+	}()
+
+	checkpoint := CheckpointEntry{}
+	var resumeToken bson.Raw
+	if checkpointStore != nil {
+		entry, ok, err := checkpointStore.Load(collectionName)
+		if err != nil {
+			return fmt.Errorf("failed to load checkpoint: %w", err)
+		}
+		if ok {
+			checkpoint = entry
+			if len(entry.ResumeTokenBytes) > 0 {
+				resumeToken = bson.Raw(entry.ResumeTokenBytes)
+			}
+		}
+	}
+
+	sourceCollection := sourceClient.Database(params.SourceDB).Collection(collectionName)
+	destCollection := destClient.Database(params.DestinationDB).Collection(collectionName)
+
+	streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup).SetMaxAwaitTime(tailMaxAwaitTime)
+	if resumeToken != nil {
+		streamOptions.SetResumeAfter(resumeToken)
+	}
+
+	stream, err := sourceCollection.Watch(ctx, mongo.Pipeline{}, streamOptions)
+	if err != nil {
+		return fmt.Errorf("failed to open change stream for %s: %w", collectionName, err)
+	}
+	defer stream.Close(context.Background())
+
+	interval := params.TailBatchInterval
+	if interval <= 0 {
+		interval = defaultTailBatchInterval
+	}
+	log.Printf("Tailing changes for %s (batch interval %s)", collectionName, interval)
+
+	var pending []mongo.WriteModel
+
+	flush := func(ctx context.Context) error {
+		if len(pending) == 0 {
+			return nil
+		}
+
+		bulkOpts := options.BulkWrite().SetOrdered(false)
+		_, _, err := retryWithBackoff(ctx, retryCfg, fmt.Sprintf("apply tail batch for %s", collectionName), func() error {
+			_, e := destCollection.BulkWrite(ctx, pending, bulkOpts)
+			return e
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply tail batch for %s: %w", collectionName, err)
+		}
+		pending = pending[:0]
+
+		if checkpointStore != nil {
+			checkpoint.ResumeTokenBytes = []byte(stream.ResumeToken())
+			if err := checkpointStore.Save(collectionName, checkpoint); err != nil {
+				return fmt.Errorf("failed to save tail checkpoint for %s: %w", collectionName, err)
+			}
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Drain whatever has already been buffered before exiting. ctx is
+			// already cancelled here, so the final write uses a fresh one.
+			if err := flush(context.Background()); err != nil {
+				return err
+			}
+			log.Printf("Stopped tailing %s: %v", collectionName, ctx.Err())
+			return nil
+
+		case <-ticker.C:
+			if err := flush(ctx); err != nil {
+				return err
+			}
+
+		default:
+			if !stream.TryNext(ctx) {
+				// An error caused by ctx being cancelled mid-TryNext is left
+				// to the ctx.Done branch, which drains pending first.
+				if err := stream.Err(); err != nil && ctx.Err() == nil {
+					return fmt.Errorf("change stream error for %s: %w", collectionName, err)
+				}
+				continue
+			}
+
+			var event changeEvent
+			if err := stream.Decode(&event); err != nil {
+				return fmt.Errorf("failed to decode change event for %s: %w", collectionName, err)
+			}
+
+			model, err := writeModelForEvent(event)
+			if err != nil {
+				return fmt.Errorf("failed to translate change event for %s: %w", collectionName, err)
+			}
+			if model != nil {
+				pending = append(pending, model)
+			}
+		}
+	}
+}
+
+// writeModelForEvent maps a change-stream event to the destination write
+// that replicates it: insert/update/replace become an upserting
+// ReplaceOne, delete becomes a DeleteOne. Unrecognized operation types
+// (e.g. "drop", "invalidate") are ignored.
+func writeModelForEvent(event changeEvent) (mongo.WriteModel, error) {
+	switch event.OperationType {
+	case "insert", "update", "replace", "delete":
+	default:
+		// These events (e.g. "drop", "invalidate") carry no documentKey.
+		return nil, nil
+	}
+
+	var key bson.M
+	if err := bson.Unmarshal(event.DocumentKey, &key); err != nil {
+		return nil, fmt.Errorf("failed to decode documentKey: %w", err)
+	}
+
+	switch event.OperationType {
+	case "insert", "update", "replace":
+		if len(event.FullDocument) == 0 {
+			// The document was deleted before the update-lookup resolved, a
+			// standard MongoDB race. Skip it rather than erroring: the
+			// subsequent delete event (if any) will clean up the destination.
+			return nil, nil
+		}
+
+		var doc bson.M
+		if err := bson.Unmarshal(event.FullDocument, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode fullDocument: %w", err)
+		}
+		return mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: key["_id"]}}).
+			SetReplacement(doc).
+			SetUpsert(true), nil
+	case "delete":
+		return mongo.NewDeleteOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: key["_id"]}}), nil
+	default:
+		return nil, nil
+	}
+}
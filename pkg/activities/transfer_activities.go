@@ -6,14 +6,17 @@ import (
 	"log"
 
 	"github.com/mouradhm/mongo-transfert/pkg/models"
+	"github.com/mouradhm/mongo-transfert/pkg/transform"
 )
 
 // ValidateConnections validates the MongoDB connections
 func ValidateConnections(ctx context.Context, params models.TransferParams) error {
 	log.Println("Validating MongoDB connections")
 
+	retryCfg := newRetryConfig(params.MaxRetries, params.RetryBaseDelay)
+
 	// Validate source connection
-	sourceClient, err := connectToMongoDB(ctx, params.SourceURI)
+	sourceClient, err := connectToMongoDB(ctx, params.SourceURI, retryCfg)
 	if err != nil {
 		return fmt.Errorf("failed to connect to source MongoDB: %w", err)
 	}
@@ -24,7 +27,7 @@ func ValidateConnections(ctx context.Context, params models.TransferParams) erro
 	}()
 
 	// Validate destination connection
-	destClient, err := connectToMongoDB(ctx, params.DestinationURI)
+	destClient, err := connectToMongoDB(ctx, params.DestinationURI, retryCfg)
 	if err != nil {
 		return fmt.Errorf("failed to connect to destination MongoDB: %w", err)
 	}
@@ -43,7 +46,7 @@ func GetCollections(ctx context.Context, params models.TransferParams) ([]string
 	log.Printf("Getting collections from source database: %s", params.SourceDB)
 
 	// Connect to source MongoDB
-	sourceClient, err := connectToMongoDB(ctx, params.SourceURI)
+	sourceClient, err := connectToMongoDB(ctx, params.SourceURI, newRetryConfig(params.MaxRetries, params.RetryBaseDelay))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to source MongoDB: %w", err)
 	}
@@ -74,8 +77,13 @@ func GetCollections(ctx context.Context, params models.TransferParams) ([]string
 	return collections, nil
 }
 
-// TransferCollection transfers a single collection from source to destination
-func TransferCollection(ctx context.Context, params models.TransferParams, collectionName string) (models.CollectionTransferResult, error) {
+// TransferCollection transfers a single collection from source to
+// destination. checkpointStore is nil unless params.StateFile is set, in
+// which case the caller must share a single instance across every
+// concurrently-transferred collection: each Save/MarkDone rewrites the
+// whole state file from the store's in-memory map, so two independent
+// stores racing on the same file would clobber each other's progress.
+func TransferCollection(ctx context.Context, params models.TransferParams, collectionName string, checkpointStore CheckpointStore) (models.CollectionTransferResult, error) {
 	result := models.CollectionTransferResult{
 		CollectionName: collectionName,
 		Success:        false,
@@ -83,8 +91,10 @@ func TransferCollection(ctx context.Context, params models.TransferParams, colle
 
 	log.Printf("Starting transfer of collection: %s", collectionName)
 
+	retryCfg := newRetryConfig(params.MaxRetries, params.RetryBaseDelay)
+
 	// Connect to source MongoDB
-	sourceClient, err := connectToMongoDB(ctx, params.SourceURI)
+	sourceClient, err := connectToMongoDB(ctx, params.SourceURI, retryCfg)
 	if err != nil {
 		result.ErrorMessage = fmt.Sprintf("Failed to connect to source MongoDB: %v", err)
 		return result, err
@@ -96,7 +106,7 @@ func TransferCollection(ctx context.Context, params models.TransferParams, colle
 	}()
 
 	// Connect to destination MongoDB
-	destClient, err := connectToMongoDB(ctx, params.DestinationURI)
+	destClient, err := connectToMongoDB(ctx, params.DestinationURI, retryCfg)
 	if err != nil {
 		result.ErrorMessage = fmt.Sprintf("Failed to connect to destination MongoDB: %v", err)
 		return result, err
@@ -107,8 +117,22 @@ func TransferCollection(ctx context.Context, params models.TransferParams, colle
 		}
 	}()
 
+	// Load the transform/filter pipeline for this collection, if configured
+	var transformChain transform.Chain
+	var aggPipeline *transform.AggregationPipeline
+	if params.TransformConfigPath != "" {
+		transformConfig, err := transform.LoadConfig(params.TransformConfigPath)
+		if err != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to load transform config: %v", err)
+			return result, err
+		}
+		collConfig := transformConfig.ForCollection(collectionName)
+		transformChain = collConfig.Chain()
+		aggPipeline = collConfig.Pipeline
+	}
+
 	// Transfer the collection
-	count, err := transferCollection(
+	outcome, err := transferCollection(
 		ctx,
 		sourceClient,
 		destClient,
@@ -117,16 +141,52 @@ func TransferCollection(ctx context.Context, params models.TransferParams, colle
 		collectionName,
 		params.BatchSize,
 		params.OverwriteDestination,
+		checkpointStore,
+		retryCfg,
+		transformChain,
+		aggPipeline,
+		params.IntraCollectionWorkers,
 	)
 
+	result.RetryCount = outcome.retryCount
+	result.LastRetryReason = outcome.lastRetryReason
+	result.FilteredCount = outcome.filteredCount
+	result.DroppedCount = outcome.droppedCount
+
 	if err != nil {
 		result.ErrorMessage = fmt.Sprintf("Failed to transfer collection: %v", err)
 		return result, err
 	}
 
-	result.DocumentsCount = count
+	result.DocumentsCount = outcome.count
 	result.Success = true
-	log.Printf("Collection transfer completed: %s, %d documents", collectionName, count)
+	log.Printf("Collection transfer completed: %s, %d documents (%d retries)", collectionName, outcome.count, outcome.retryCount)
+
+	if params.Verify {
+		sourceCollection := sourceClient.Database(params.SourceDB).Collection(collectionName)
+		destCollection := destClient.Database(params.DestinationDB).Collection(collectionName)
+
+		verifyOutcome, verr := VerifyCollection(ctx, sourceCollection, destCollection, params.VerifySampleSize)
+		if verr != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to verify collection: %v", verr)
+			result.Success = false
+			return result, verr
+		}
+
+		result.SourceCount = verifyOutcome.SourceCount
+		result.DestCount = verifyOutcome.DestCount
+		result.ChecksumMatch = verifyOutcome.ChecksumMatch
+		result.SampledDiffs = verifyOutcome.SampledDiffs
+
+		if !verifyOutcome.ChecksumMatch {
+			result.Success = false
+			result.ErrorMessage = fmt.Sprintf(
+				"verification failed: source has %d documents, destination has %d (%d sampled diffs)",
+				verifyOutcome.SourceCount, verifyOutcome.DestCount, len(verifyOutcome.SampledDiffs),
+			)
+			log.Printf("Collection %s failed verification: %s", collectionName, result.ErrorMessage)
+		}
+	}
 
 	return result, nil
 }
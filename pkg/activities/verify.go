@@ -0,0 +1,188 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultVerifySampleSize bounds the number of documents fetched and
+// diffed in full when a collection's checksum comparison disagrees.
+const defaultVerifySampleSize = 20
+
+// VerifyOutcome is the result of comparing a collection's source and
+// destination after its transfer completes.
+type VerifyOutcome struct {
+	SourceCount   int
+	DestCount     int
+	ChecksumMatch bool
+	SampledDiffs  []string
+}
+
+// VerifyCollection compares sourceCollection and destCollection without
+// re-reading every document over the wire: it first compares a
+// $group-computed count and checksum on each side, and only falls back to
+// a bounded random-sample document diff when those disagree.
+func VerifyCollection(ctx context.Context, sourceCollection *mongo.Collection, destCollection *mongo.Collection, sampleSize int) (VerifyOutcome, error) {
+	outcome := VerifyOutcome{ChecksumMatch: true}
+
+	if sampleSize <= 0 {
+		sampleSize = defaultVerifySampleSize
+	}
+
+	sourceCount, sourceChecksum, err := collectionChecksum(ctx, sourceCollection)
+	if err != nil {
+		return outcome, fmt.Errorf("failed to checksum source collection: %w", err)
+	}
+	destCount, destChecksum, err := collectionChecksum(ctx, destCollection)
+	if err != nil {
+		return outcome, fmt.Errorf("failed to checksum destination collection: %w", err)
+	}
+
+	outcome.SourceCount = int(sourceCount)
+	outcome.DestCount = int(destCount)
+
+	if sourceCount != destCount || sourceChecksum != destChecksum {
+		outcome.ChecksumMatch = false
+
+		diffs, err := sampleDiff(ctx, sourceCollection, destCollection, sampleSize)
+		if err != nil {
+			return outcome, fmt.Errorf("failed to sample-diff collection: %w", err)
+		}
+		outcome.SampledDiffs = diffs
+	}
+
+	return outcome, nil
+}
+
+// checksumResult is the shape of the single document produced by the
+// $group-based count+checksum aggregation.
+type checksumResult struct {
+	Count    int64 `bson:"count"`
+	Checksum int64 `bson:"checksum"`
+}
+
+// collectionChecksum computes a collection's document count and an
+// order-independent checksum of its _id values via a single $group
+// aggregation, avoiding a full document read over the wire. It falls back
+// to hashing sorted _id batches when $toHashedIndexKey isn't available
+// (e.g. older server versions).
+func collectionChecksum(ctx context.Context, collection *mongo.Collection) (int64, int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "checksum", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$toHashedIndexKey", Value: "$_id"}}}}},
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err == nil {
+		var results []checksumResult
+		decodeErr := cursor.All(ctx, &results)
+		cursor.Close(ctx)
+		if decodeErr == nil {
+			if len(results) == 0 {
+				return 0, 0, nil
+			}
+			return results[0].Count, results[0].Checksum, nil
+		}
+	}
+
+	log.Printf("$toHashedIndexKey unavailable for %s, falling back to md5-based checksum", collection.Name())
+	return md5Checksum(ctx, collection)
+}
+
+// md5Checksum is the fallback count+checksum path for deployments without
+// $toHashedIndexKey: it streams _id values sorted ascending and sums an
+// md5 digest of each into a running, order-independent total.
+func md5Checksum(ctx context.Context, collection *mongo.Collection) (int64, int64, error) {
+	findOptions := options.Find().
+		SetProjection(bson.D{{Key: "_id", Value: 1}}).
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetBatchSize(1000)
+
+	cursor, err := collection.Find(ctx, bson.D{}, findOptions)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find _id values: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var count int64
+	var checksum int64
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return 0, 0, fmt.Errorf("failed to decode _id: %w", err)
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to marshal _id: %w", err)
+		}
+		sum := md5.Sum(raw)
+		checksum += int64(uint32(sum[0])<<24 | uint32(sum[1])<<16 | uint32(sum[2])<<8 | uint32(sum[3]))
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, 0, fmt.Errorf("cursor error: %w", err)
+	}
+
+	return count, checksum, nil
+}
+
+// sampleDiff fetches up to sampleSize random documents from
+// sourceCollection and compares each one's full contents against
+// destCollection, returning a human-readable description of every
+// divergence found.
+func sampleDiff(ctx context.Context, sourceCollection *mongo.Collection, destCollection *mongo.Collection, sampleSize int) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: sampleSize}}}},
+	}
+
+	cursor, err := sourceCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample source documents: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var diffs []string
+	for cursor.Next(ctx) {
+		var sourceDoc bson.M
+		if err := cursor.Decode(&sourceDoc); err != nil {
+			return nil, fmt.Errorf("failed to decode sampled document: %w", err)
+		}
+
+		id := sourceDoc["_id"]
+		var destDoc bson.M
+		err := destCollection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&destDoc)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			diffs = append(diffs, fmt.Sprintf("_id %v: missing in destination", id))
+		case err != nil:
+			return nil, fmt.Errorf("failed to fetch destination document %v: %w", id, err)
+		default:
+			sourceRaw, err := bson.Marshal(sourceDoc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal source document %v: %w", id, err)
+			}
+			destRaw, err := bson.Marshal(destDoc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal destination document %v: %w", id, err)
+			}
+			if !bytes.Equal(sourceRaw, destRaw) {
+				diffs = append(diffs, fmt.Sprintf("_id %v: content mismatch", id))
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+
+	return diffs, nil
+}
@@ -0,0 +1,69 @@
+package activities
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCollectionChecksum(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("uses the $group result when available", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.verify_test", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: nil}, {Key: "count", Value: int64(3)}, {Key: "checksum", Value: int64(42)}},
+		))
+
+		count, checksum, err := collectionChecksum(context.Background(), mt.Coll)
+		if err != nil {
+			t.Fatalf("collectionChecksum returned error: %v", err)
+		}
+		if count != 3 || checksum != 42 {
+			t.Errorf("collectionChecksum() = (%d, %d), want (3, 42)", count, checksum)
+		}
+	})
+
+	mt.Run("returns zero for an empty collection", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.verify_test", mtest.FirstBatch))
+
+		count, checksum, err := collectionChecksum(context.Background(), mt.Coll)
+		if err != nil {
+			t.Fatalf("collectionChecksum returned error: %v", err)
+		}
+		if count != 0 || checksum != 0 {
+			t.Errorf("collectionChecksum() = (%d, %d), want (0, 0)", count, checksum)
+		}
+	})
+}
+
+func TestVerifyCollectionAgreesWhenChecksumsMatch(t *testing.T) {
+	source := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	dest := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	source.Run("matching checksums skip the sample diff", func(source *mtest.T) {
+		dest.Run("", func(dest *mtest.T) {
+			source.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.source", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: nil}, {Key: "count", Value: int64(5)}, {Key: "checksum", Value: int64(7)}},
+			))
+			dest.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.dest", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: nil}, {Key: "count", Value: int64(5)}, {Key: "checksum", Value: int64(7)}},
+			))
+
+			outcome, err := VerifyCollection(context.Background(), source.Coll, dest.Coll, 20)
+			if err != nil {
+				t.Fatalf("VerifyCollection returned error: %v", err)
+			}
+			if !outcome.ChecksumMatch {
+				t.Errorf("ChecksumMatch = false, want true")
+			}
+			if outcome.SourceCount != 5 || outcome.DestCount != 5 {
+				t.Errorf("SourceCount/DestCount = %d/%d, want 5/5", outcome.SourceCount, outcome.DestCount)
+			}
+			if len(outcome.SampledDiffs) != 0 {
+				t.Errorf("SampledDiffs = %v, want none when checksums already agree", outcome.SampledDiffs)
+			}
+		})
+	})
+}
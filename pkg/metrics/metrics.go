@@ -0,0 +1,83 @@
+// Package metrics defines the Prometheus collectors exposed by the
+// mongo-transfert CLI's embedded metrics server.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// DocumentsTotal counts documents processed per collection, by status
+	// ("transferred" or "filtered").
+	DocumentsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_transfer_documents_total",
+		Help: "Documents processed per collection, by status.",
+	}, []string{"collection", "status"})
+
+	// BatchDurationSeconds tracks how long each batch insert into the
+	// destination takes, per collection.
+	BatchDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "mongo_transfer_batch_duration_seconds",
+		Help: "Time to insert one batch into the destination, per collection.",
+	}, []string{"collection"})
+
+	// BytesTotal counts bytes transferred, by direction ("read" or "written").
+	BytesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_transfer_bytes_total",
+		Help: "Bytes transferred, by direction.",
+	}, []string{"direction"})
+
+	// ActiveWorkers is the number of worker goroutines currently
+	// transferring a collection.
+	ActiveWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mongo_transfer_active_workers",
+		Help: "Number of worker goroutines currently transferring a collection.",
+	})
+
+	// RetriesTotal counts retries performed, by reason.
+	RetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_transfer_retries_total",
+		Help: "Retries performed, by reason.",
+	}, []string{"reason"})
+
+	// CollectionProgressRatio is the fraction of a collection's documents
+	// transferred so far, in [0, 1].
+	CollectionProgressRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mongo_transfer_collection_progress_ratio",
+		Help: "Fraction of a collection's documents transferred so far (0-1).",
+	}, []string{"collection"})
+)
+
+// RecordBatch updates the document and duration metrics after n documents
+// were successfully inserted into collectionName.
+func RecordBatch(collectionName string, n int, duration time.Duration) {
+	DocumentsTotal.WithLabelValues(collectionName, "transferred").Add(float64(n))
+	BatchDurationSeconds.WithLabelValues(collectionName).Observe(duration.Seconds())
+}
+
+// RecordFiltered records n documents dropped by the transform pipeline's filter.
+func RecordFiltered(collectionName string, n int) {
+	DocumentsTotal.WithLabelValues(collectionName, "filtered").Add(float64(n))
+}
+
+// RecordRetry records one retry attributed to reason.
+func RecordRetry(reason string) {
+	RetriesTotal.WithLabelValues(reason).Inc()
+}
+
+// RecordBytes adds n bytes transferred in the given direction ("read" or "written").
+func RecordBytes(direction string, n int64) {
+	BytesTotal.WithLabelValues(direction).Add(float64(n))
+}
+
+// SetProgress sets collectionName's completion ratio given how many of
+// total documents have been transferred. A non-positive total is a no-op.
+func SetProgress(collectionName string, transferred, total int64) {
+	if total <= 0 {
+		return
+	}
+	CollectionProgressRatio.WithLabelValues(collectionName).Set(float64(transferred) / float64(total))
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // TransferParams contains the parameters needed for MongoDB collection transfer
 type TransferParams struct {
 	SourceURI      string   `json:"sourceUri"`
@@ -8,6 +10,59 @@ type TransferParams struct {
 	DestinationDB  string   `json:"destinationDb"`
 	Collections    []string `json:"collections"`
 	BatchSize      int      `json:"batchSize,omitempty"`
+
+	// OverwriteDestination allows dropping and recreating a destination
+	// collection that already exists. Without it, transferring into an
+	// existing destination collection fails rather than clobbering it.
+	OverwriteDestination bool `json:"overwriteDestination,omitempty"`
+
+	// StateFile, when set, enables checkpoint/resume support: progress is
+	// persisted to this JSON file so an interrupted transfer can continue
+	// from the last successfully copied document on the next run.
+	StateFile string `json:"stateFile,omitempty"`
+
+	// MaxRetries caps how many times a transient MongoDB error is retried
+	// before it is treated as permanent. Defaults to 5 when unset.
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// RetryBaseDelay is the base delay for exponential backoff between
+	// retries (actual delay also includes jitter). Defaults to 200ms when unset.
+	RetryBaseDelay time.Duration `json:"retryBaseDelay,omitempty"`
+
+	// Mode selects how the transfer behaves once the initial bulk copy
+	// finishes: "oneshot" (default) stops there, while "incremental" and
+	// "oneshot-then-tail" continue tailing each collection's change stream
+	// to keep the destination in sync.
+	Mode string `json:"mode,omitempty"`
+	// TailBatchInterval is how often change-stream events are batched and
+	// applied to the destination in tail mode. Defaults to 2s when unset.
+	TailBatchInterval time.Duration `json:"tailBatchInterval,omitempty"`
+
+	// TransformConfigPath, when set, points at a YAML/JSON file describing
+	// per-collection field renames, drops, PII hashing, document filters
+	// and/or an aggregation pipeline to apply before insertion.
+	TransformConfigPath string `json:"transformConfigPath,omitempty"`
+
+	// MetricsAddr, when set, starts an HTTP server on this address exposing
+	// Prometheus metrics at /metrics and live transfer progress at /progress.
+	MetricsAddr string `json:"metricsAddr,omitempty"`
+
+	// IntraCollectionWorkers, when greater than 1, splits a single
+	// collection's transfer across this many goroutines by partitioning it
+	// into contiguous _id ranges. Requires a comparable _id type (ObjectID,
+	// numeric, string, or date); falls back to a single-threaded transfer
+	// otherwise. Defaults to 1 (single-threaded) when unset.
+	IntraCollectionWorkers int `json:"intraCollectionWorkers,omitempty"`
+
+	// Verify, when true, runs a post-transfer consistency check between
+	// source and destination for each collection once its transfer
+	// completes. Not supported together with TransformConfigPath, since
+	// verification compares raw document checksums and content that a
+	// transform pipeline is free to change.
+	Verify bool `json:"verify,omitempty"`
+	// VerifySampleSize caps how many documents are fetched and diffed in
+	// full when a collection's checksum comparison disagrees. Defaults to
+	// 20 when unset.
+	VerifySampleSize int `json:"verifySampleSize,omitempty"`
 }
 
 // CollectionTransferResult contains the result of a single collection transfer
@@ -16,6 +71,29 @@ type CollectionTransferResult struct {
 	DocumentsCount int    `json:"documentsCount"`
 	Success        bool   `json:"success"`
 	ErrorMessage   string `json:"errorMessage,omitempty"`
+
+	// RetryCount is the number of transient-error retries performed while
+	// transferring this collection.
+	RetryCount int `json:"retryCount,omitempty"`
+	// LastRetryReason is the error message of the most recent retry, if any.
+	LastRetryReason string `json:"lastRetryReason,omitempty"`
+
+	// FilteredCount is the number of documents dropped by the transform
+	// pipeline's filter instead of being inserted into the destination.
+	FilteredCount int `json:"filteredCount,omitempty"`
+
+	// DroppedCount is the number of documents that failed to insert into
+	// the destination for a permanent (non-retryable) reason, e.g. a
+	// validator rejection. These are excluded from DocumentsCount.
+	DroppedCount int `json:"droppedCount,omitempty"`
+
+	// SourceCount, DestCount, ChecksumMatch and SampledDiffs are populated
+	// when verification (--verify) is enabled, after this collection's
+	// transfer completes.
+	SourceCount   int      `json:"sourceCount,omitempty"`
+	DestCount     int      `json:"destCount,omitempty"`
+	ChecksumMatch bool     `json:"checksumMatch,omitempty"`
+	SampledDiffs  []string `json:"sampledDiffs,omitempty"`
 }
 
 // TransferResult contains the overall result of the MongoDB transfer workflow
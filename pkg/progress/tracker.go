@@ -0,0 +1,132 @@
+// Package progress tracks the live, in-process state of a running
+// transfer so the CLI's /progress HTTP endpoint can report throughput and
+// ETA without tailing logs.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// CollectionProgress is a snapshot of one collection's live transfer state.
+type CollectionProgress struct {
+	CollectionName   string
+	DocumentsCount   int64
+	TotalCount       int64
+	BytesTransferred int64
+	StartedAt        time.Time
+	UpdatedAt        time.Time
+	Done             bool
+}
+
+// DocsPerSecond returns the collection's average throughput so far.
+func (c CollectionProgress) DocsPerSecond() float64 {
+	elapsed := c.UpdatedAt.Sub(c.StartedAt).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(c.DocumentsCount) / elapsed
+}
+
+// BytesPerSecond returns the collection's average byte throughput so far.
+func (c CollectionProgress) BytesPerSecond() float64 {
+	elapsed := c.UpdatedAt.Sub(c.StartedAt).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(c.BytesTransferred) / elapsed
+}
+
+// ETA estimates the remaining time to finish this collection based on its
+// throughput so far. It returns 0 when unknown (no total, or no progress yet).
+func (c CollectionProgress) ETA() time.Duration {
+	rate := c.DocsPerSecond()
+	if rate <= 0 || c.TotalCount <= 0 || c.DocumentsCount >= c.TotalCount {
+		return 0
+	}
+	remaining := float64(c.TotalCount - c.DocumentsCount)
+	return time.Duration(remaining/rate*1000) * time.Millisecond
+}
+
+// Tracker holds the live progress of every collection in a transfer.
+type Tracker struct {
+	mu          sync.RWMutex
+	collections map[string]*CollectionProgress
+}
+
+// NewTracker creates an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{collections: make(map[string]*CollectionProgress)}
+}
+
+// Start registers collectionName as in progress, expecting totalCount
+// documents overall.
+func (t *Tracker) Start(collectionName string, totalCount int64) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.collections[collectionName] = &CollectionProgress{
+		CollectionName: collectionName,
+		TotalCount:     totalCount,
+		StartedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// Add advances collectionName's progress by documents/bytes, creating an
+// entry for it if Start was never called.
+func (t *Tracker) Add(collectionName string, documents int64, bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cp, ok := t.collections[collectionName]
+	if !ok {
+		cp = &CollectionProgress{CollectionName: collectionName, StartedAt: time.Now()}
+		t.collections[collectionName] = cp
+	}
+	cp.DocumentsCount += documents
+	cp.BytesTransferred += bytes
+	cp.UpdatedAt = time.Now()
+}
+
+// Finish marks collectionName as done.
+func (t *Tracker) Finish(collectionName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cp, ok := t.collections[collectionName]; ok {
+		cp.Done = true
+		cp.UpdatedAt = time.Now()
+	}
+}
+
+// Snapshot returns a point-in-time copy of every collection's progress.
+func (t *Tracker) Snapshot() []CollectionProgress {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	out := make([]CollectionProgress, 0, len(t.collections))
+	for _, cp := range t.collections {
+		out = append(out, *cp)
+	}
+	return out
+}
+
+// defaultTracker is the process-wide tracker used by the package-level
+// functions below, mirroring the way Prometheus collectors register
+// against a default registry.
+var defaultTracker = NewTracker()
+
+// Start registers collectionName on the default tracker.
+func Start(collectionName string, totalCount int64) { defaultTracker.Start(collectionName, totalCount) }
+
+// Add advances collectionName's progress on the default tracker.
+func Add(collectionName string, documents int64, bytes int64) {
+	defaultTracker.Add(collectionName, documents, bytes)
+}
+
+// Finish marks collectionName as done on the default tracker.
+func Finish(collectionName string) { defaultTracker.Finish(collectionName) }
+
+// Snapshot returns a point-in-time copy of the default tracker's progress.
+func Snapshot() []CollectionProgress { return defaultTracker.Snapshot() }
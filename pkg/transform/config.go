@@ -0,0 +1,85 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v3"
+)
+
+// CollectionConfig is the transform/filter configuration for one
+// collection, or the "default" entry applied to collections that don't
+// have their own.
+type CollectionConfig struct {
+	Renames    []FieldRename        `yaml:"renames" json:"renames"`
+	Drop       []string             `yaml:"drop" json:"drop"`
+	HashSHA256 []string             `yaml:"hashSha256" json:"hashSha256"`
+	Filter     bson.M               `yaml:"filter" json:"filter"`
+	Pipeline   *AggregationPipeline `yaml:"pipeline" json:"pipeline"`
+}
+
+// Config is the document loaded from --transform-config.
+type Config struct {
+	Default     CollectionConfig            `yaml:"default" json:"default"`
+	Collections map[string]CollectionConfig `yaml:"collections" json:"collections"`
+}
+
+// LoadConfig reads a transform config file, parsed as YAML or JSON
+// depending on its extension (".json" for JSON, anything else as YAML).
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform config %s: %w", path, err)
+	}
+
+	var cfg Config
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse transform config %s: %w", path, err)
+		}
+	} else {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse transform config %s: %w", path, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// ForCollection returns the CollectionConfig for collectionName, falling
+// back to Default when no collection-specific entry exists. A nil Config
+// yields the zero CollectionConfig (no-op).
+func (c *Config) ForCollection(collectionName string) CollectionConfig {
+	if c == nil {
+		return CollectionConfig{}
+	}
+	if cfg, ok := c.Collections[collectionName]; ok {
+		return cfg
+	}
+	return c.Default
+}
+
+// Chain builds the ordered Transformer chain for this config: renames,
+// then drops, then hashing, then the document-level filter. The
+// AggregationPipeline (if any) is applied separately at read time, not
+// through this chain.
+func (cc CollectionConfig) Chain() Chain {
+	var chain Chain
+	for _, r := range cc.Renames {
+		chain = append(chain, r)
+	}
+	if len(cc.Drop) > 0 {
+		chain = append(chain, FieldDrop{Fields: cc.Drop})
+	}
+	if len(cc.HashSHA256) > 0 {
+		chain = append(chain, FieldHashSHA256{Fields: cc.HashSHA256})
+	}
+	if len(cc.Filter) > 0 {
+		chain = append(chain, FilterExpr{Expr: cc.Filter})
+	}
+	return chain
+}
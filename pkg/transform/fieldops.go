@@ -0,0 +1,57 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FieldRename renames a field, leaving the document unchanged if the
+// source field is absent.
+type FieldRename struct {
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
+}
+
+// Apply implements Transformer.
+func (r FieldRename) Apply(doc bson.M) (bson.M, bool, error) {
+	if v, ok := doc[r.From]; ok {
+		doc[r.To] = v
+		delete(doc, r.From)
+	}
+	return doc, true, nil
+}
+
+// FieldDrop removes the given top-level fields from the document.
+type FieldDrop struct {
+	Fields []string `yaml:"fields" json:"fields"`
+}
+
+// Apply implements Transformer.
+func (d FieldDrop) Apply(doc bson.M) (bson.M, bool, error) {
+	for _, field := range d.Fields {
+		delete(doc, field)
+	}
+	return doc, true, nil
+}
+
+// FieldHashSHA256 replaces each field's value with the hex-encoded
+// SHA-256 hash of its string representation, for pseudonymizing PII.
+type FieldHashSHA256 struct {
+	Fields []string `yaml:"fields" json:"fields"`
+}
+
+// Apply implements Transformer.
+func (h FieldHashSHA256) Apply(doc bson.M) (bson.M, bool, error) {
+	for _, field := range h.Fields {
+		v, ok := doc[field]
+		if !ok || v == nil {
+			continue
+		}
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%v", v)))
+		doc[field] = hex.EncodeToString(sum[:])
+	}
+	return doc, true, nil
+}
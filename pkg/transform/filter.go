@@ -0,0 +1,200 @@
+package transform
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FilterExpr drops documents that don't match Expr, a subset of the
+// MongoDB query language: implicit equality, $eq, $ne, $gt, $gte, $lt,
+// $lte, $in, $nin and $exists on top-level fields, composed with
+// top-level $and / $or.
+type FilterExpr struct {
+	Expr bson.M `yaml:"expr" json:"expr"`
+}
+
+// Apply implements Transformer. The document itself is never modified.
+func (f FilterExpr) Apply(doc bson.M) (bson.M, bool, error) {
+	match, err := matchAll(doc, f.Expr)
+	if err != nil {
+		return doc, false, err
+	}
+	return doc, match, nil
+}
+
+func matchAll(doc bson.M, expr bson.M) (bool, error) {
+	for key, cond := range expr {
+		switch key {
+		case "$and":
+			clauses, err := asExprList(cond)
+			if err != nil {
+				return false, err
+			}
+			for _, clause := range clauses {
+				ok, err := matchAll(doc, clause)
+				if err != nil || !ok {
+					return false, err
+				}
+			}
+
+		case "$or":
+			clauses, err := asExprList(cond)
+			if err != nil {
+				return false, err
+			}
+			matched := false
+			for _, clause := range clauses {
+				ok, err := matchAll(doc, clause)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false, nil
+			}
+
+		default:
+			if !matchField(doc[key], cond) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+func asExprList(cond interface{}) ([]bson.M, error) {
+	raw, ok := cond.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("expected an array of expressions, got %T", cond)
+	}
+	clauses := make([]bson.M, 0, len(raw))
+	for _, c := range raw {
+		m, ok := c.(bson.M)
+		if !ok {
+			return nil, fmt.Errorf("expected an expression document, got %T", c)
+		}
+		clauses = append(clauses, m)
+	}
+	return clauses, nil
+}
+
+func matchField(value interface{}, cond interface{}) bool {
+	condMap, ok := cond.(bson.M)
+	if !ok {
+		return reflect.DeepEqual(value, cond)
+	}
+
+	for op, operand := range condMap {
+		switch op {
+		case "$eq":
+			if !reflect.DeepEqual(value, operand) {
+				return false
+			}
+		case "$ne":
+			if reflect.DeepEqual(value, operand) {
+				return false
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			cmp, ok := compare(value, operand)
+			if !ok {
+				return false
+			}
+			if op == "$gt" && cmp <= 0 {
+				return false
+			}
+			if op == "$gte" && cmp < 0 {
+				return false
+			}
+			if op == "$lt" && cmp >= 0 {
+				return false
+			}
+			if op == "$lte" && cmp > 0 {
+				return false
+			}
+		case "$in":
+			if !containsAny(operand, value) {
+				return false
+			}
+		case "$nin":
+			if containsAny(operand, value) {
+				return false
+			}
+		case "$exists":
+			want, _ := operand.(bool)
+			if (value != nil) != want {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// compare returns (-1, 0, 1) for orderable numeric or string values, and
+// ok=false if a and b aren't comparable this way.
+func compare(a, b interface{}) (int, bool) {
+	if af, aok := toFloat64(a); aok {
+		if bf, bok := toFloat64(b); bok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+
+	if as, aok := a.(string); aok {
+		if bs, bok := b.(string); bok {
+			switch {
+			case as < bs:
+				return -1, true
+			case as > bs:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func containsAny(list interface{}, value interface{}) bool {
+	arr, ok := list.(bson.A)
+	if !ok {
+		return false
+	}
+	for _, candidate := range arr {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
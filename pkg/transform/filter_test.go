@@ -0,0 +1,143 @@
+package transform
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFilterExprApply(t *testing.T) {
+	cases := []struct {
+		name string
+		expr bson.M
+		doc  bson.M
+		keep bool
+	}{
+		{
+			name: "implicit equality match",
+			expr: bson.M{"status": "active"},
+			doc:  bson.M{"status": "active"},
+			keep: true,
+		},
+		{
+			name: "implicit equality mismatch",
+			expr: bson.M{"status": "active"},
+			doc:  bson.M{"status": "inactive"},
+			keep: false,
+		},
+		{
+			name: "$ne matches",
+			expr: bson.M{"status": bson.M{"$ne": "archived"}},
+			doc:  bson.M{"status": "active"},
+			keep: true,
+		},
+		{
+			name: "$gt/$lte range",
+			expr: bson.M{"age": bson.M{"$gt": 18, "$lte": 65}},
+			doc:  bson.M{"age": 40},
+			keep: true,
+		},
+		{
+			name: "$gt/$lte range excludes boundary",
+			expr: bson.M{"age": bson.M{"$gt": 18, "$lte": 65}},
+			doc:  bson.M{"age": 18},
+			keep: false,
+		},
+		{
+			name: "$in matches",
+			expr: bson.M{"role": bson.M{"$in": bson.A{"admin", "owner"}}},
+			doc:  bson.M{"role": "owner"},
+			keep: true,
+		},
+		{
+			name: "$nin excludes",
+			expr: bson.M{"role": bson.M{"$nin": bson.A{"admin", "owner"}}},
+			doc:  bson.M{"role": "owner"},
+			keep: false,
+		},
+		{
+			name: "$exists true requires field present",
+			expr: bson.M{"email": bson.M{"$exists": true}},
+			doc:  bson.M{"name": "ada"},
+			keep: false,
+		},
+		{
+			name: "$exists false requires field absent",
+			expr: bson.M{"email": bson.M{"$exists": false}},
+			doc:  bson.M{"name": "ada"},
+			keep: true,
+		},
+		{
+			name: "$and requires every clause",
+			expr: bson.M{"$and": bson.A{
+				bson.M{"status": "active"},
+				bson.M{"age": bson.M{"$gte": 18}},
+			}},
+			doc:  bson.M{"status": "active", "age": 30},
+			keep: true,
+		},
+		{
+			name: "$and fails on one clause",
+			expr: bson.M{"$and": bson.A{
+				bson.M{"status": "active"},
+				bson.M{"age": bson.M{"$gte": 18}},
+			}},
+			doc:  bson.M{"status": "active", "age": 12},
+			keep: false,
+		},
+		{
+			name: "$or matches on one clause",
+			expr: bson.M{"$or": bson.A{
+				bson.M{"status": "active"},
+				bson.M{"status": "trial"},
+			}},
+			doc:  bson.M{"status": "trial"},
+			keep: true,
+		},
+		{
+			name: "string comparison",
+			expr: bson.M{"name": bson.M{"$gte": "m"}},
+			doc:  bson.M{"name": "zed"},
+			keep: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := FilterExpr{Expr: tc.expr}
+			out, keep, err := f.Apply(tc.doc)
+			if err != nil {
+				t.Fatalf("Apply returned error: %v", err)
+			}
+			if keep != tc.keep {
+				t.Errorf("Apply() keep = %v, want %v", keep, tc.keep)
+			}
+			if out == nil && tc.doc != nil {
+				t.Errorf("Apply() returned nil document")
+			}
+		})
+	}
+}
+
+func TestFilterExprApplyReturnsSameDocument(t *testing.T) {
+	doc := bson.M{"status": "active"}
+	f := FilterExpr{Expr: bson.M{"status": "active"}}
+
+	out, keep, err := f.Apply(doc)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if !keep {
+		t.Fatalf("Apply() keep = false, want true")
+	}
+	if out["status"] != doc["status"] {
+		t.Errorf("Apply() returned a document with different contents than the input")
+	}
+}
+
+func TestAsExprListRejectsNonArray(t *testing.T) {
+	f := FilterExpr{Expr: bson.M{"$and": "not-an-array"}}
+	if _, _, err := f.Apply(bson.M{}); err == nil {
+		t.Errorf("Apply() expected an error for a malformed $and expression")
+	}
+}
@@ -0,0 +1,11 @@
+package transform
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// AggregationPipeline, when set on a CollectionConfig, replaces the plain
+// Find used to read from the source collection with an Aggregate call
+// using these stages, pushing projections and filters down to the server
+// instead of applying them client-side.
+type AggregationPipeline struct {
+	Stages bson.A `yaml:"stages" json:"stages"`
+}
@@ -0,0 +1,34 @@
+// Package transform implements the per-collection document pipeline used
+// to rename, drop, pseudonymize, and filter documents as they move from
+// the source to the destination collection.
+package transform
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// Transformer applies one transformation step to a document read from the
+// source collection before it is written to the destination. Returning
+// keep=false drops the document from the transfer entirely (it is counted
+// as filtered, not transferred).
+type Transformer interface {
+	Apply(doc bson.M) (out bson.M, keep bool, err error)
+}
+
+// Chain runs a document through a sequence of Transformers in order,
+// short-circuiting as soon as one of them drops the document.
+type Chain []Transformer
+
+// Apply runs doc through every Transformer in the chain.
+func (c Chain) Apply(doc bson.M) (bson.M, bool, error) {
+	keep := true
+	var err error
+	for _, t := range c {
+		doc, keep, err = t.Apply(doc)
+		if err != nil {
+			return nil, false, err
+		}
+		if !keep {
+			return nil, false, nil
+		}
+	}
+	return doc, true, nil
+}